@@ -0,0 +1,82 @@
+package fantasy
+
+import (
+	"testing"
+
+	origfantasy "charm.land/fantasy"
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// toolParts collects the tool call and tool result parts of msgs as ID -> tool name or result text.
+func toolParts(t *testing.T, msgs []origfantasy.Message) (calls, results map[string]string) {
+	t.Helper()
+	calls, results = map[string]string{}, map[string]string{}
+	for _, m := range msgs {
+		for _, part := range m.Content {
+			if p, ok := origfantasy.AsMessagePart[origfantasy.ToolCallPart](part); ok {
+				if m.Role != origfantasy.MessageRoleAssistant {
+					t.Errorf("tool call %s in a %s message", p.ToolCallID, m.Role)
+				}
+				calls[p.ToolCallID] = p.ToolName
+			} else if p, ok := origfantasy.AsMessagePart[origfantasy.ToolResultPart](part); ok {
+				if m.Role != origfantasy.MessageRoleTool {
+					t.Errorf("tool result %s in a %s message", p.ToolCallID, m.Role)
+				}
+				results[p.ToolCallID] = toolResultText(p.Output)
+			}
+		}
+	}
+	return calls, results
+}
+
+func TestToolCallRoundTrip(t *testing.T) {
+	in := []origfantasy.Message{
+		{Role: origfantasy.MessageRoleUser, Content: []origfantasy.MessagePart{origfantasy.TextPart{Text: "weather in Paris and Rome?"}}},
+		{Role: origfantasy.MessageRoleAssistant, Content: []origfantasy.MessagePart{
+			origfantasy.ToolCallPart{ToolCallID: "c1", ToolName: "weather", Input: `{"city":"Paris"}`},
+			origfantasy.ToolCallPart{ToolCallID: "c2", ToolName: "weather", Input: `{"city":"Rome"}`},
+		}},
+		{Role: origfantasy.MessageRoleTool, Content: []origfantasy.MessagePart{
+			origfantasy.ToolResultPart{ToolCallID: "c1", Output: origfantasy.ToolResultOutputContentText{Text: "rain"}},
+			origfantasy.ToolResultPart{ToolCallID: "c2", Output: origfantasy.ToolResultOutputContentText{Text: "sun"}},
+		}},
+		{Role: origfantasy.MessageRoleAssistant, Content: []origfantasy.MessagePart{origfantasy.TextPart{Text: "Rain in Paris, sun in Rome."}}},
+	}
+
+	stored := FromFantasy(in)
+	if len(stored) != 5 {
+		t.Fatalf("FromFantasy returned %d messages, want 5 (one per tool result)", len(stored))
+	}
+	if len(stored[1].ToolCalls) != 2 || stored[1].ToolCalls[1].Arguments != `{"city":"Rome"}` {
+		t.Fatalf("assistant tool calls = %+v", stored[1].ToolCalls)
+	}
+
+	calls, results := toolParts(t, ToFantasy(stored))
+	want := map[string]string{"c1": "rain", "c2": "sun"}
+	if len(calls) != 2 || calls["c1"] != "weather" || calls["c2"] != "weather" {
+		t.Fatalf("tool calls = %v, want c1 and c2", calls)
+	}
+	for id, text := range want {
+		if results[id] != text {
+			t.Errorf("result of %s = %q, want %q", id, results[id], text)
+		}
+	}
+}
+
+func TestToFantasyOldToolFormat(t *testing.T) {
+	msgs := []memorybox.Message{
+		{Role: memorybox.AssistantRole, ToolCalls: []memorybox.ToolCall{{ID: "c1", Name: "weather"}}},
+		// Tool results were stored as JSON in the content before ToolCallID existed
+		{Role: memorybox.ToolRole, Content: `{"tool_call_id":"c1","content":"rain"}`},
+	}
+	calls, results := toolParts(t, ToFantasy(msgs))
+	if calls["c1"] != "weather" || results["c1"] != "rain" {
+		t.Fatalf("tool calls = %v, results = %v; want c1 with its result rain", calls, results)
+	}
+
+	// A tool message that is not JSON is kept as text
+	plain := ToFantasy([]memorybox.Message{{Role: memorybox.ToolRole, Content: "rain"}})
+	if p, ok := origfantasy.AsMessagePart[origfantasy.TextPart](plain[0].Content[0]); !ok || p.Text != "rain" {
+		t.Fatalf("plain tool message = %+v", plain[0].Content)
+	}
+}
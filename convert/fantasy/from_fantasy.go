@@ -0,0 +1,60 @@
+package fantasy
+
+import (
+	"strings"
+
+	origfantasy "charm.land/fantasy"
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// FromFantasy converts fantasy messages, e.g. the Messages of an agent step, back into memorybox messages.
+// Tool call parts become ToolCalls of the assistant message and every tool result becomes
+// a separate tool message with its ToolCallID, so the history can be replayed with ToFantasy.
+func FromFantasy(msgs []origfantasy.Message) []memorybox.Message {
+	out := make([]memorybox.Message, 0, len(msgs))
+	for _, m := range msgs {
+		var text strings.Builder
+		var toolCalls []memorybox.ToolCall
+		var results []memorybox.Message
+
+		for _, part := range m.Content {
+			if p, ok := origfantasy.AsMessagePart[origfantasy.TextPart](part); ok {
+				text.WriteString(p.Text)
+			} else if p, ok := origfantasy.AsMessagePart[origfantasy.ToolCallPart](part); ok {
+				toolCalls = append(toolCalls, memorybox.ToolCall{
+					ID:        p.ToolCallID,
+					Name:      p.ToolName,
+					Arguments: p.Input,
+				})
+			} else if p, ok := origfantasy.AsMessagePart[origfantasy.ToolResultPart](part); ok {
+				results = append(results, memorybox.Message{
+					Role:       memorybox.ToolRole,
+					Content:    toolResultText(p.Output),
+					ToolCallID: p.ToolCallID,
+				})
+			}
+		}
+
+		if len(results) > 0 {
+			out = append(out, results...)
+			continue
+		}
+		out = append(out, memorybox.Message{
+			Role:      memorybox.Role(m.Role),
+			Content:   text.String(),
+			ToolCalls: toolCalls,
+		})
+	}
+	return out
+}
+
+// toolResultText returns the text of a tool result output.
+func toolResultText(output origfantasy.ToolResultOutputContent) string {
+	if text, ok := origfantasy.AsToolResultOutputType[origfantasy.ToolResultOutputContentText](output); ok {
+		return text.Text
+	}
+	if e, ok := origfantasy.AsToolResultOutputType[origfantasy.ToolResultOutputContentError](output); ok && e.Error != nil {
+		return e.Error.Error()
+	}
+	return ""
+}
@@ -19,3 +19,5 @@ require (
 	github.com/kaptinlin/messageformat-go v0.4.7 // indirect
 	golang.org/x/text v0.32.0 // indirect
 )
+
+replace github.com/rmay1er/magic-memory-box-go => ../../
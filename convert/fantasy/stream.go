@@ -0,0 +1,79 @@
+package fantasy
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// ErrStreamClosed is returned by OnTextDelta after Close.
+var ErrStreamClosed = errors.New("memory stream closed")
+
+// MemoryStream stores the text of a fantasy agent stream in a MemoryBox while it arrives.
+// Pass OnTextDelta as the AgentStreamCall callback and call Close after Stream returns:
+//
+//	stream := convert.NewMemoryStream(ctx, box, "user")
+//	_, err := agent.Stream(ctx, fantasy.AgentStreamCall{
+//		Messages:    convert.ToFantasy(history),
+//		OnTextDelta: stream.OnTextDelta,
+//	})
+//	msgs, memErr := stream.Close()
+//
+// Partial replies are checkpointed by MemoryBox.RememberChunks; if ctx is cancelled
+// the reply is saved as interrupted.
+type MemoryStream struct {
+	ctx    context.Context
+	chunks chan string
+	done   chan struct{}
+	mu     sync.Mutex // guards closed, so a delta is never sent on a closed channel
+	closed bool
+	msgs   []memorybox.Message
+	err    error
+}
+
+// NewMemoryStream starts remembering a streamed assistant reply for the user.
+func NewMemoryStream(ctx context.Context, box memorybox.IMemoryBox, userid string) *MemoryStream {
+	s := &MemoryStream{
+		ctx:    ctx,
+		chunks: make(chan string),
+		done:   make(chan struct{}),
+	}
+	go func() {
+		defer close(s.done)
+		s.msgs, s.err = box.RememberChunks(ctx, userid, s.chunks)
+	}()
+	return s
+}
+
+// OnTextDelta matches fantasy.OnTextDeltaFunc and forwards every text delta to the memory.
+// It returns ErrStreamClosed after Close.
+func (s *MemoryStream) OnTextDelta(id, text string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		return ErrStreamClosed
+	}
+	select {
+	case s.chunks <- text:
+		return nil
+	case <-s.done:
+		return s.err
+	case <-s.ctx.Done():
+		return s.ctx.Err()
+	}
+}
+
+// Close finalizes the reply and returns the stored history.
+// It is safe to call Close more than once.
+func (s *MemoryStream) Close() ([]memorybox.Message, error) {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.chunks)
+	}
+	s.mu.Unlock()
+	<-s.done
+	return s.msgs, s.err
+}
@@ -12,9 +12,29 @@ func ToFantasy(msgs []memorybox.Message) []origfantasy.Message {
 	for _, m := range msgs {
 		var content []origfantasy.MessagePart
 		switch m.Role {
-		case "user", "system", "assistant":
+		case "user", "system":
 			content = []origfantasy.MessagePart{origfantasy.TextPart{Text: m.Content}}
+		case "assistant":
+			if m.Content != "" || len(m.ToolCalls) == 0 {
+				content = []origfantasy.MessagePart{origfantasy.TextPart{Text: m.Content}}
+			}
+			// Emit the tool calls so that the following tool results have a matching call
+			for _, tc := range m.ToolCalls {
+				content = append(content, origfantasy.ToolCallPart{
+					ToolCallID: tc.ID,
+					ToolName:   tc.Name,
+					Input:      tc.Arguments,
+				})
+			}
 		case "tool":
+			if m.ToolCallID != "" {
+				content = []origfantasy.MessagePart{origfantasy.ToolResultPart{
+					ToolCallID: m.ToolCallID,
+					Output:     origfantasy.ToolResultOutputContentText{Text: m.Content},
+				}}
+				break
+			}
+			// Older histories store tool results as {"tool_call_id", "content"} JSON
 			var data map[string]string
 			if err := json.Unmarshal([]byte(m.Content), &data); err == nil {
 				content = []origfantasy.MessagePart{origfantasy.ToolResultPart{
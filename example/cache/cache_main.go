@@ -81,38 +81,32 @@ func CacheExample() {
 			box.AddRaw(ctx, "ruslan", memorybox.SystemRole, "You are Neo from Matrix")
 		}
 
-		// Pass the user's input to the MemoryBox.Talk method,
-		// which prepares the full conversation context for the AI.
-		userMsgs, err := box.Tell(ctx, "ruslan", input)
-		if err != nil {
-			// If something goes wrong, print the error and exit the loop.
-			fmt.Printf("MemoryBox error: %v\n", err)
-
-		}
-
-		// Call the AI client to generate a response using the conversation messages.
-		// Convert memorybox messages into the format expected by the AI client.
-		resp, err := client.Generate(&text.GPT4SeriesInput{
-			Messages: convert.ToReplicate(userMsgs), // or use inline convert.ToReplicate(box.TellUnsafe(ctx, "ruslan", input))
+		// Run the turn with Exchange: the user's input is only saved together with the AI reply,
+		// so a failed generation does not leave an unanswered message in the history.
+		_, err = box.Exchange(ctx, "ruslan", input, func(ctx context.Context, msgs []memorybox.Message) ([]memorybox.Message, error) {
+			// Call the AI client to generate a response using the conversation messages.
+			// Convert memorybox messages into the format expected by the AI client.
+			resp, err := client.Generate(&text.GPT4SeriesInput{
+				Messages: convert.ToReplicate(msgs),
+			})
+			if err != nil {
+				return nil, err
+			}
+
+			// Join the AI output pieces into a single string and trim spaces.
+			reply := strings.TrimSpace(strings.Join(resp.Output, ""))
+
+			// Print the AI's response labeled as "Нео:" (Neo).
+			fmt.Printf("Нео: %s\n", reply)
+
+			// Return the reply so that it is remembered together with the user's input.
+			return []memorybox.Message{{Role: memorybox.AssistantRole, Content: reply}}, nil
 		})
 		if err != nil {
-			// Print AI generation errors and stop.
-			fmt.Printf("Generation error: %v\n", err)
+			// Print AI generation or memory errors and stop.
+			fmt.Printf("Exchange error: %v\n", err)
 			break
 		}
-
-		// Join the AI output pieces into a single string and trim spaces.
-		reply := strings.TrimSpace(strings.Join(resp.Output, ""))
-
-		// Print the AI's response labeled as "Нео:" (Neo).
-		fmt.Printf("Нео: %#v\n", resp.Output)
-
-		// Remember the AI's reply to append to the conversation history.
-		_, err = box.Remember(ctx, "ruslan", reply)
-		if err != nil {
-			// Handle errors while saving memory.
-			fmt.Printf("MemoryBox error: %v\n", err)
-		}
 	}
 }
 
@@ -3,7 +3,6 @@ package main
 import (
 	"bufio"
 	"context"
-	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
@@ -35,9 +34,11 @@ func FantasyExample() {
 	// Create a new MemoryBox instance using the cache and configuration settings.
 	// ContextLenSize defines how many recent messages to keep.
 	// ExpireTime sets how long memories are kept before they are dropped.
+	// ToolSafePolicy makes sure trimming never separates a tool call from its results.
 	box := memorybox.NewMemoryBox(cache, memorybox.MemoryBoxConfig{
 		ContextLenSize: 20,
 		ExpireTime:     time.Hour,
+		TrimPolicy:     memorybox.ToolSafePolicy{Policy: memorybox.KeepSystemPolicy{MaxMessages: 20}},
 	})
 
 	// Choose your fave provider.
@@ -116,13 +117,13 @@ func FantasyExample() {
 			break
 		}
 
-		// Remember tool results if any tools were used
+		// Remember every step of the answer: assistant tool calls, tool results and the final reply.
+		// Storing the calls next to their results lets ToFantasy send valid tool call/result pairs next turn.
 		for _, step := range resp.Steps {
-			for _, tr := range step.Content.ToolResults() {
-				if text, ok := fantasy.AsToolResultOutputType[fantasy.ToolResultOutputContentText](tr.Result); ok {
-					data := map[string]string{"tool_call_id": tr.ToolCallID, "content": text.Text}
-					jsonData, _ := json.Marshal(data)
-					box.AddRaw(ctx, "user", memorybox.ToolRole, string(jsonData))
+			for _, msg := range convert.FromFantasy(step.Messages) {
+				if _, err := box.AddMessage(ctx, "user", msg); err != nil {
+					// Handle errors while saving memory.
+					fmt.Printf("MemoryBox error: %v\n", err)
 				}
 			}
 		}
@@ -130,13 +131,6 @@ func FantasyExample() {
 		// Print the AI's response labeled as "AI:".
 		fmt.Printf("AI: %#v\n", resp.Response.Content.Text())
 
-		// Remember the AI's reply to append to the conversation history.
-		_, err = box.Remember(ctx, "user", resp.Response.Content.Text())
-		if err != nil {
-			// Handle errors while saving memory.
-			fmt.Printf("MemoryBox error: %v\n", err)
-		}
-
 		// Colored output of the messages array for testing
 		fmt.Printf("\033[32mMessages: %+v\033[0m\n", userMsgs)
 	}
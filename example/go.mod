@@ -77,3 +77,9 @@ require (
 	google.golang.org/grpc v1.74.2 // indirect
 	google.golang.org/protobuf v1.36.7 // indirect
 )
+
+replace (
+	github.com/rmay1er/magic-memory-box-go => ../
+	github.com/rmay1er/magic-memory-box-go/convert/fantasy => ../convert/fantasy
+	github.com/rmay1er/magic-memory-box-go/rdb => ../rdb
+)
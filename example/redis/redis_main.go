@@ -35,8 +35,10 @@ func RedisExample() {
 		DB:   0, // select Redis database 0 (default DB)
 	})
 
-	// wrap Redis client into adapter for MemoryBox usage, with namespace "tests"
-	rdb := rdb.NewRedisAdapter(redisClient, "tests", true)
+	// wrap Redis client into adapter for MemoryBox usage, with namespace "tests";
+	// the namespace is ephemeral: keys expire on their own and are deleted when the example exits
+	rdb := rdb.NewRedisAdapter(redisClient, "tests", rdb.WithEphemeral(2*time.Hour), rdb.WithFlushOnClose())
+	defer rdb.Close(ctx)
 
 	// configure MemoryBox to keep last 10 messages for context and set expiration for memories after 2 hours
 	box := memorybox.NewMemoryBox(rdb, memorybox.MemoryBoxConfig{
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// ShardedExample compares MemoryCache with ShardedCache under parallel Tell/Remember load.
+// Every goroutine talks to its own set of users, like a bot handling many chats at once.
+// For precise numbers run the benchmarks instead: go test -bench Parallel ./memorybox
+func ShardedExample() {
+	const (
+		workers = 32   // Parallel chats being served
+		turns   = 5000 // Tell/Remember turns per worker
+	)
+
+	backends := []struct {
+		name  string
+		cache memorybox.IMemorizer
+	}{
+		{"MemoryCache", memorybox.NewCache()},
+		{"ShardedCache(64)", memorybox.NewShardedCache(64)},
+	}
+
+	for _, backend := range backends {
+		// Keep the last 20 messages of every conversation for one hour.
+		box := memorybox.NewMemoryBox(backend.cache, memorybox.MemoryBoxConfig{
+			ContextLenSize: 20,
+			ExpireTime:     time.Hour,
+		})
+
+		ctx := context.Background()
+		start := time.Now()
+		var wg sync.WaitGroup
+		for w := 0; w < workers; w++ {
+			wg.Add(1)
+			go func(w int) {
+				defer wg.Done()
+				// Each worker gets its own block of 1000 users.
+				for i := 0; i < turns; i++ {
+					userid := "user-" + strconv.Itoa(w*1000+i%1000)
+					box.Tell(ctx, userid, "Hello! How are you?")
+					box.Remember(ctx, userid, "I'm doing great, thank you!")
+				}
+			}(w)
+		}
+		wg.Wait()
+
+		elapsed := time.Since(start)
+		fmt.Printf("%-18s %v, %.0f turns/s\n", backend.name, elapsed.Round(time.Millisecond), float64(workers*turns)/elapsed.Seconds())
+	}
+}
+
+func main() {
+	ShardedExample()
+}
@@ -0,0 +1,118 @@
+package filestore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// header is the first line of every data file; the value follows it verbatim.
+type header struct {
+	Key    string    `json:"key"`
+	Expire time.Time `json:"expire,omitzero"`
+}
+
+// path returns the data file of key. Keys are hashed, so any key makes a valid file name.
+func (s *Store) path(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return filepath.Join(s.dir, dataDir, hex.EncodeToString(sum[:]))
+}
+
+// writeFile atomically replaces the data file of key: the content is written to a temporary file,
+// synced and renamed over the old file, and the directory is synced to persist the rename.
+func (s *Store) writeFile(key string, h header, value string) error {
+	head, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Join(s.dir, tmpDir), "write-*")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	ok := false
+	defer func() {
+		if !ok {
+			f.Close()
+			os.Remove(tmp)
+		}
+	}()
+
+	w := bufio.NewWriter(f)
+	w.Write(head)
+	w.WriteByte('\n')
+	w.WriteString(value)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path(key)); err != nil {
+		return err
+	}
+	ok = true
+	return syncDir(filepath.Join(s.dir, dataDir))
+}
+
+// readValue returns the value stored in the data file of key.
+func readValue(path string, key string) (string, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return "", memorybox.ErrNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+
+	head, value, ok := bytes.Cut(data, []byte{'\n'})
+	var h header
+	if !ok || json.Unmarshal(head, &h) != nil {
+		return "", fmt.Errorf("%w: %s: bad header", memorybox.ErrCorrupted, path)
+	}
+	if h.Key != key {
+		return "", fmt.Errorf("%w: %s holds %q, not %q", memorybox.ErrCorrupted, path, h.Key, key)
+	}
+	return string(value), nil
+}
+
+// readHeader reads only the header line of a data file.
+func readHeader(path string) (header, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return header{}, err
+	}
+	defer f.Close()
+
+	line, err := bufio.NewReader(f).ReadBytes('\n')
+	if err != nil {
+		return header{}, fmt.Errorf("%w: no header: %w", memorybox.ErrCorrupted, err)
+	}
+	var h header
+	if err := json.Unmarshal(line, &h); err != nil {
+		return header{}, fmt.Errorf("%w: bad header: %w", memorybox.ErrCorrupted, err)
+	}
+	return h, nil
+}
+
+// syncDir flushes a directory so that renames and removals in it survive a crash.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
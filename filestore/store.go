@@ -0,0 +1,292 @@
+// Package filestore is an embedded memorybox backend for CLI tools and desktop assistants:
+// a plain directory with one file per key, without a server or cgo.
+//
+// Every write goes to a temporary file that is synced and then renamed over the old one,
+// so a crash mid-write leaves either the old or the new value. TTLs are kept in an in-memory
+// expiry index rebuilt from the file headers on Open; Compact removes expired files and the
+// temporary files of interrupted writes.
+//
+//	store, err := filestore.Open(filepath.Join(configDir, "memory"), filestore.WithCompaction(time.Hour))
+//	defer store.Close()
+//	box := memorybox.NewMemoryBox(store, memorybox.MemoryBoxConfig{ExpireTime: 30 * 24 * time.Hour})
+package filestore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+const (
+	dataDir = "data" // One file per key, named by the hash of the key.
+	tmpDir  = "tmp"  // Files being written; leftovers are removed by Open and Compact.
+)
+
+// Store implements memorybox.IMemorizer, IUpdater and IExpirer on a directory.
+// It is safe for concurrent use by multiple goroutines of one process;
+// the directory must not be shared between processes.
+type Store struct {
+	dir   string
+	clock memorybox.Clock
+
+	mu      sync.RWMutex
+	expires map[string]time.Time // Expiry index: every stored key, zero time for keys without a TTL.
+
+	compactInterval time.Duration  // How often Compact runs in the background; zero disables it.
+	stop            chan struct{}  // Closed by Close to stop the compaction.
+	closeOnce       sync.Once      // Makes Close idempotent.
+	background      sync.WaitGroup // Tracks the compaction goroutine.
+}
+
+// Option configures a Store created by Open.
+type Option func(*Store)
+
+// WithCompaction runs Compact every interval in the background. Call Close to stop it.
+func WithCompaction(interval time.Duration) Option {
+	return func(s *Store) {
+		s.compactInterval = interval
+	}
+}
+
+// WithClock makes the store read the time from clock instead of the system clock.
+func WithClock(clock memorybox.Clock) Option {
+	return func(s *Store) {
+		s.clock = clock
+	}
+}
+
+// Open opens the store in dir, creating the directory if needed, and builds the expiry index
+// from the headers of the stored files. Files that cannot be read are logged and skipped.
+func Open(dir string, opts ...Option) (*Store, error) {
+	s := &Store{
+		dir:     dir,
+		clock:   memorybox.SystemClock,
+		expires: map[string]time.Time{},
+		stop:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	for _, sub := range []string{dataDir, tmpDir} {
+		if err := os.MkdirAll(filepath.Join(dir, sub), 0o700); err != nil {
+			return nil, err
+		}
+	}
+	if err := s.removeTemp(); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(filepath.Join(dir, dataDir))
+	if err != nil {
+		return nil, err
+	}
+	for _, e := range entries {
+		path := filepath.Join(dir, dataDir, e.Name())
+		h, err := readHeader(path)
+		if err != nil {
+			slog.Error("filestore: skipping unreadable file", "path", path, "err", err)
+			continue
+		}
+		s.expires[h.Key] = h.Expire
+	}
+
+	if s.compactInterval > 0 {
+		ticker := s.clock.NewTicker(s.compactInterval)
+		s.background.Add(1)
+		go s.compactor(ticker)
+	}
+	return s, nil
+}
+
+// Close stops the background compaction, if any. It is safe to call Close more than once.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.background.Wait()
+	})
+	return nil
+}
+
+// Set stores a value with an optional expiration time. memorybox.KeepTTL keeps the TTL of an existing key.
+// Context parameter is accepted for future extensibility but currently not used.
+func (s *Store) Set(ctx context.Context, key string, value any, expiration ...time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set(key, fmt.Sprintf("%v", value), expiration...)
+}
+
+// Get returns the value of key, memorybox.ErrNotFound if it does not exist
+// or memorybox.ErrExpired if its TTL has passed.
+// Context parameter is accepted for future extensibility but currently not used.
+func (s *Store) Get(ctx context.Context, key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.get(key)
+}
+
+// Update atomically reads the value stored under key, passes it to fn and stores the result.
+// Expired keys are reported to fn as missing.
+// Context parameter is accepted for future extensibility but currently not used.
+func (s *Store) Update(ctx context.Context, key string, fn func(old string, exists bool) (string, error), expiration ...time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	old, err := s.get(key)
+	exists := err == nil
+	if err != nil && !errors.Is(err, memorybox.ErrNotFound) {
+		return err
+	}
+
+	value, err := fn(old, exists)
+	if err != nil {
+		return err
+	}
+	return s.set(key, value, expiration...)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+// Context parameter is accepted for future extensibility but currently not used.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remove(key)
+}
+
+// Expire sets the TTL of an existing key; zero or less removes the TTL.
+// Expiring a missing or expired key is not an error.
+// Context parameter is accepted for future extensibility but currently not used.
+func (s *Store) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, err := s.get(key)
+	if errors.Is(err, memorybox.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	return s.set(key, value, expiration)
+}
+
+// GetEx returns the value of key like Get and sets its TTL like Expire.
+// Context parameter is accepted for future extensibility but currently not used.
+func (s *Store) GetEx(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	value, err := s.get(key)
+	if err != nil {
+		return "", err
+	}
+	return value, s.set(key, value, expiration)
+}
+
+// Compact removes the files of expired keys and the temporary files of interrupted writes,
+// and returns the number of expired keys removed.
+func (s *Store) Compact() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.clock.Now()
+	n := 0
+	for key, expire := range s.expires {
+		if expired(expire, now) {
+			if err := s.remove(key); err != nil {
+				return n, err
+			}
+			n++
+		}
+	}
+	return n, s.removeTemp()
+}
+
+// compactor runs Compact on every tick until Close is called.
+func (s *Store) compactor(ticker memorybox.Ticker) {
+	defer s.background.Done()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			if _, err := s.Compact(); err != nil {
+				slog.Error("filestore: compact", "err", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// get returns the value of key. The caller must hold the lock.
+func (s *Store) get(key string) (string, error) {
+	expire, ok := s.expires[key]
+	if !ok {
+		return "", memorybox.ErrNotFound
+	}
+	if expired(expire, s.clock.Now()) {
+		return "", memorybox.ErrExpired
+	}
+	return readValue(s.path(key), key)
+}
+
+// set writes the value of key. KeepTTL keeps the expiration time of an existing, unexpired key.
+// The caller must hold the write lock.
+func (s *Store) set(key string, value string, expiration ...time.Duration) error {
+	now := s.clock.Now()
+	var expire time.Time
+	switch {
+	case len(expiration) == 0:
+	case expiration[0] == memorybox.KeepTTL:
+		if old, ok := s.expires[key]; ok && !expired(old, now) {
+			expire = old
+		}
+	case expiration[0] > 0:
+		expire = now.Add(expiration[0])
+	}
+
+	if err := s.writeFile(key, header{Key: key, Expire: expire}, value); err != nil {
+		return err
+	}
+	s.expires[key] = expire
+	return nil
+}
+
+// remove deletes the file of key. The caller must hold the write lock.
+func (s *Store) remove(key string) error {
+	if _, ok := s.expires[key]; !ok {
+		return nil
+	}
+	if err := os.Remove(s.path(key)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	delete(s.expires, key)
+	return syncDir(filepath.Join(s.dir, dataDir))
+}
+
+// removeTemp deletes the temporary files left by interrupted writes.
+func (s *Store) removeTemp() error {
+	entries, err := os.ReadDir(filepath.Join(s.dir, tmpDir))
+	if err != nil {
+		return err
+	}
+	for _, e := range entries {
+		if err := os.Remove(filepath.Join(s.dir, tmpDir, e.Name())); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	return nil
+}
+
+// expired reports whether an expiration time has passed at now. The zero time never expires.
+func expired(expire time.Time, now time.Time) bool {
+	return !expire.IsZero() && now.After(expire)
+}
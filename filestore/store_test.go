@@ -0,0 +1,171 @@
+package filestore_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/filestore"
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+	"github.com/rmay1er/magic-memory-box-go/memorybox/clocktest"
+)
+
+// files returns the names of the files in a subdirectory of the store.
+func files(t *testing.T, dir, sub string) []string {
+	t.Helper()
+	entries, err := os.ReadDir(filepath.Join(dir, sub))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	return names
+}
+
+// wantValue fails the test unless key holds want.
+func wantValue(t *testing.T, s *filestore.Store, key, want string) {
+	t.Helper()
+	got, err := s.Get(context.Background(), key)
+	if err != nil || got != want {
+		t.Fatalf("Get(%q) = %q, %v; want %q", key, got, err, want)
+	}
+}
+
+func TestOpenAfterCrash(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store, err := filestore.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	store.Set(ctx, "a", "1")
+	store.Close()
+
+	// A crash mid-write leaves a temporary file; a data file without a complete header cannot be read.
+	if err := os.WriteFile(filepath.Join(dir, "tmp", "write-123"), []byte(`{"key":"a"}`+"\n2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "data", "torn"), []byte(`{"key":"b","exp`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	store, err = filestore.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+	if tmp := files(t, dir, "tmp"); len(tmp) != 0 {
+		t.Fatalf("temporary files after Open = %v", tmp)
+	}
+	wantValue(t, store, "a", "1")
+	if _, err := store.Get(ctx, "b"); !errors.Is(err, memorybox.ErrNotFound) {
+		t.Fatalf("Get(b) error = %v, want ErrNotFound", err)
+	}
+	if err := store.Set(ctx, "b", "2"); err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, store, "b", "2")
+}
+
+func TestCompact(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	clock := clocktest.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	store, err := filestore.Open(dir, filestore.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	store.Set(ctx, "expired", "1", time.Minute)
+	store.Set(ctx, "kept", "2", time.Hour)
+	store.Set(ctx, "forever", "3")
+	if err := os.WriteFile(filepath.Join(dir, "tmp", "write-123"), []byte("partial"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Minute)
+
+	n, err := store.Compact()
+	if err != nil || n != 1 {
+		t.Fatalf("Compact = %d, %v; want 1", n, err)
+	}
+	if data := files(t, dir, "data"); len(data) != 2 {
+		t.Fatalf("data files after Compact = %v, want 2", data)
+	}
+	if tmp := files(t, dir, "tmp"); len(tmp) != 0 {
+		t.Fatalf("temporary files after Compact = %v", tmp)
+	}
+	wantValue(t, store, "kept", "2")
+	wantValue(t, store, "forever", "3")
+}
+
+func TestConcurrentUpdate(t *testing.T) {
+	const writers, increments = 10, 20
+	ctx := context.Background()
+	store, err := filestore.Open(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < increments; i++ {
+				err := store.Update(ctx, "counter", func(old string, exists bool) (string, error) {
+					n, _ := strconv.Atoi(old)
+					return strconv.Itoa(n + 1), nil
+				})
+				if err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	wantValue(t, store, "counter", strconv.Itoa(writers*increments))
+}
+
+func TestStoreSentinelErrors(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	clock := clocktest.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	store, err := filestore.Open(dir, filestore.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer store.Close()
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, memorybox.ErrNotFound) {
+		t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+
+	store.Set(ctx, "user", "hi", time.Minute)
+	clock.Advance(2 * time.Minute)
+	_, err = store.Get(ctx, "user")
+	if !errors.Is(err, memorybox.ErrExpired) || !errors.Is(err, memorybox.ErrNotFound) {
+		t.Fatalf("Get(expired) error = %v, want ErrExpired matching ErrNotFound", err)
+	}
+
+	store.Set(ctx, "user", "hi")
+	files, err := filepath.Glob(filepath.Join(dir, "data", "*"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("data files = %v, %v", files, err)
+	}
+	if err := os.WriteFile(files[0], []byte("no header"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "user"); !errors.Is(err, memorybox.ErrCorrupted) {
+		t.Fatalf("Get(corrupted) error = %v, want ErrCorrupted", err)
+	}
+}
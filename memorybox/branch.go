@@ -0,0 +1,313 @@
+package memorybox
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// MainBranch is the ID of the branch every conversation starts with.
+const MainBranch = "main"
+
+// Branch is one line of a conversation tree: the path from the root message to Head.
+type Branch struct {
+	ID        string    `json:"id"`         // Branch identifier, MainBranch for the initial branch
+	Head      string    `json:"head"`       // ID of the last message on the branch, empty for an empty branch
+	CreatedAt time.Time `json:"created_at"` // When the branch was forked
+	Active    bool      `json:"-"`          // Set by ListBranches for the branch GetMemories returns
+}
+
+// conversation is a message tree with named branches.
+// While it has a single branch it is stored as a plain JSON array of messages,
+// which keeps the format readable by converters and older versions;
+// after the first Fork it is stored as a JSON object.
+type conversation struct {
+	Active   string    `json:"active"`
+	Branches []Branch  `json:"branches"`
+	Messages []Message `json:"messages"`
+}
+
+// Fork creates a new branch that continues the conversation from the message with the given ID
+// and makes it active. The previous continuation stays on its own branch and can be restored with Checkout.
+// A typical "edit prompt" flow forks from the message before the edited one and then calls Tell.
+func (b *MemoryBox) Fork(ctx context.Context, userid string, fromMessageID string) (Branch, error) {
+	var branch Branch
+	err := b.updateConversation(ctx, userid, func(c *conversation) error {
+		if c.node(fromMessageID) < 0 {
+			return ErrMessageNotFound
+		}
+		branch = Branch{
+			ID:        NewULID(),
+			Head:      fromMessageID,
+			CreatedAt: b.clock().Now(),
+		}
+		c.Branches = append(c.Branches, branch)
+		c.Active = branch.ID
+		return nil
+	})
+	branch.Active = err == nil
+	return branch, err
+}
+
+// ListBranches returns all branches of the user's conversation in creation order.
+func (b *MemoryBox) ListBranches(ctx context.Context, userid string) ([]Branch, error) {
+	raw, err := b.read(ctx, userid)
+	if err != nil {
+		return []Branch{}, err
+	}
+	c, err := decodeConversation(raw)
+	if err != nil {
+		return []Branch{}, err
+	}
+
+	branches := make([]Branch, len(c.Branches))
+	for i, br := range c.Branches {
+		br.Active = br.ID == c.Active
+		branches[i] = br
+	}
+	return branches, nil
+}
+
+// Checkout makes the branch with the given ID active and returns its messages.
+// It returns ErrBranchNotFound if there is no such branch.
+func (b *MemoryBox) Checkout(ctx context.Context, userid string, branchID string) ([]Message, error) {
+	var path []Message
+	err := b.updateConversation(ctx, userid, func(c *conversation) error {
+		if c.branch(branchID) < 0 {
+			return ErrBranchNotFound
+		}
+		c.Active = branchID
+		path = c.path()
+		return nil
+	})
+	return path, err
+}
+
+// updateConversation atomically applies fn to the conversation tree stored under key.
+func (b *MemoryBox) updateConversation(ctx context.Context, key string, fn func(*conversation) error) error {
+	return b.updateRaw(ctx, key, func(old string, exists bool) (string, error) {
+		if !exists {
+			old = ""
+		}
+		c, err := decodeConversation(old)
+		if err != nil {
+			return "", err
+		}
+		if err := fn(c); err != nil {
+			return "", err
+		}
+		return c.encode()
+	})
+}
+
+// decodeConversation parses a stored history in either the array or the tree format.
+// A value that is not valid JSON is reported as ErrCorrupted.
+// Messages without an ID, written by older versions, get a stable one derived from their content.
+func decodeConversation(raw string) (*conversation, error) {
+	raw = strings.TrimSpace(raw)
+	c := &conversation{}
+
+	if strings.HasPrefix(raw, "{") {
+		if err := json.Unmarshal([]byte(raw), c); err != nil {
+			return nil, fmt.Errorf("%w: history: %w", ErrCorrupted, err)
+		}
+		if c.branch(c.Active) < 0 && len(c.Branches) > 0 {
+			c.Active = c.Branches[0].ID
+		}
+		return c, nil
+	}
+
+	data := []Message{}
+	if raw != "" {
+		if err := json.Unmarshal([]byte(raw), &data); err != nil {
+			return nil, fmt.Errorf("%w: history: %w", ErrCorrupted, err)
+		}
+	}
+	// IDs must not change between reads, or an ID returned by GetMemories would be unknown to EditMessage.
+	seen := map[string]int{}
+	for i, m := range data {
+		if m.ID == "" {
+			key := fmt.Sprintf("%s\x00%s\x00%s\x00%v", m.Role, m.Name, m.Content, m.ToolCalls)
+			data[i].ID = legacyID(key, seen[key])
+			seen[key]++
+		}
+	}
+	c.Messages = data
+	c.Active = MainBranch
+	c.Branches = []Branch{{ID: MainBranch}}
+	c.setPath(data)
+	return c, nil
+}
+
+// encode serializes the conversation, using the plain array format while there is only one branch.
+func (c *conversation) encode() (string, error) {
+	var v any = c
+	if len(c.Branches) <= 1 {
+		v = c.path()
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// path returns the messages of the active branch from the root to the head.
+func (c *conversation) path() []Message {
+	i := c.branch(c.Active)
+	if i < 0 {
+		return []Message{}
+	}
+
+	byID := make(map[string]Message, len(c.Messages))
+	for _, m := range c.Messages {
+		byID[m.ID] = m
+	}
+
+	var path []Message
+	seen := map[string]bool{}
+	for id := c.Branches[i].Head; id != "" && !seen[id]; {
+		m, ok := byID[id]
+		if !ok {
+			break
+		}
+		seen[id] = true
+		path = append(path, m)
+		id = m.ParentID
+	}
+
+	// Reverse to get the root first
+	for l, r := 0, len(path)-1; l < r; l, r = l+1, r-1 {
+		path[l], path[r] = path[r], path[l]
+	}
+	if path == nil {
+		path = []Message{}
+	}
+	return path
+}
+
+// setPath makes path the content of the active branch. Messages are relinked in order,
+// new messages are added to the tree and messages no branch can reach anymore are removed.
+// Changes never leak into other branches: a message shared with another branch that is edited
+// or relinked, e.g. by trimming, is copied under a new ID and the other branches keep the original.
+func (c *conversation) setPath(path []Message) {
+	byID := make(map[string]Message, len(c.Messages))
+	for _, m := range c.Messages {
+		byID[m.ID] = m
+	}
+	shared := c.sharedNodes()
+
+	for i := range path {
+		if path[i].ID == "" {
+			path[i].ID = NewULID()
+		}
+		path[i].ParentID = ""
+		if i > 0 {
+			path[i].ParentID = path[i-1].ID
+		}
+		if old, ok := byID[path[i].ID]; ok && shared[old.ID] && !reflect.DeepEqual(old, path[i]) {
+			path[i].ID = NewULID()
+		}
+	}
+
+	updated := make(map[string]Message, len(path))
+	for _, m := range path {
+		updated[m.ID] = m
+	}
+
+	// Keep the tree order: existing nodes first, then the new ones.
+	nodes := make([]Message, 0, len(c.Messages)+len(path))
+	for _, m := range c.Messages {
+		if u, ok := updated[m.ID]; ok {
+			m = u
+			delete(updated, m.ID)
+		}
+		nodes = append(nodes, m)
+	}
+	for _, m := range path {
+		if _, ok := updated[m.ID]; ok {
+			nodes = append(nodes, m)
+		}
+	}
+	c.Messages = nodes
+
+	head := ""
+	if len(path) > 0 {
+		head = path[len(path)-1].ID
+	}
+	if i := c.branch(c.Active); i >= 0 {
+		c.Branches[i].Head = head
+	}
+	c.prune()
+}
+
+// sharedNodes returns the IDs of the messages on the paths of the inactive branches.
+func (c *conversation) sharedNodes() map[string]bool {
+	byID := make(map[string]Message, len(c.Messages))
+	for _, m := range c.Messages {
+		byID[m.ID] = m
+	}
+
+	shared := map[string]bool{}
+	for _, br := range c.Branches {
+		if br.ID == c.Active {
+			continue
+		}
+		for id := br.Head; id != "" && !shared[id]; id = byID[id].ParentID {
+			if _, ok := byID[id]; !ok {
+				break
+			}
+			shared[id] = true
+		}
+	}
+	return shared
+}
+
+// prune removes messages that are not on the path of any branch.
+func (c *conversation) prune() {
+	byID := make(map[string]Message, len(c.Messages))
+	for _, m := range c.Messages {
+		byID[m.ID] = m
+	}
+
+	reachable := map[string]bool{}
+	for _, br := range c.Branches {
+		for id := br.Head; id != "" && !reachable[id]; id = byID[id].ParentID {
+			if _, ok := byID[id]; !ok {
+				break
+			}
+			reachable[id] = true
+		}
+	}
+
+	nodes := c.Messages[:0]
+	for _, m := range c.Messages {
+		if reachable[m.ID] {
+			nodes = append(nodes, m)
+		}
+	}
+	c.Messages = nodes
+}
+
+// node returns the index of the message with the given ID, or -1.
+func (c *conversation) node(id string) int {
+	for i, m := range c.Messages {
+		if m.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// branch returns the index of the branch with the given ID, or -1.
+func (c *conversation) branch(id string) int {
+	for i, br := range c.Branches {
+		if br.ID == id {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,85 @@
+package memorybox_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// history joins the contents of msgs for compact comparisons.
+func history(msgs []memorybox.Message) string {
+	return strings.Join(contents(msgs), "|")
+}
+
+func TestBranchEditDoesNotLeak(t *testing.T) {
+	ctx := context.Background()
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{})
+
+	box.Tell(ctx, "user", "q1")
+	msgs, _ := box.Remember(ctx, "user", "a1")
+	box.Tell(ctx, "user", "q2")
+
+	branch, err := box.Fork(ctx, "user", msgs[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	box.Tell(ctx, "user", "q2 other")
+
+	// q1 is shared with main: editing it on the new branch must not change main.
+	msgs, err = box.EditMessage(ctx, "user", msgs[0].ID, "q1 edited")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(msgs); got != "q1 edited|a1|q2 other" {
+		t.Fatalf("branch = %q", got)
+	}
+
+	main, err := box.Checkout(ctx, "user", memorybox.MainBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(main); got != "q1|a1|q2" {
+		t.Fatalf("main = %q, want it unchanged", got)
+	}
+
+	// Editing on main now leaves the branch alone too.
+	box.EditMessage(ctx, "user", main[0].ID, "q1 main edit")
+	other, err := box.Checkout(ctx, "user", branch.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(other); got != "q1 edited|a1|q2 other" {
+		t.Fatalf("branch = %q after editing main", got)
+	}
+}
+
+func TestBranchTrimDoesNotLeak(t *testing.T) {
+	ctx := context.Background()
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{ContextLenSize: 3})
+
+	box.Tell(ctx, "user", "q1")
+	msgs, _ := box.Remember(ctx, "user", "a1")
+
+	// Growing the forked branch past the limit trims q1 from it, not from main.
+	if _, err := box.Fork(ctx, "user", msgs[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	box.Tell(ctx, "user", "q2")
+	msgs, err := box.Remember(ctx, "user", "a2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(msgs); got != "a1|q2|a2" {
+		t.Fatalf("branch = %q", got)
+	}
+
+	main, err := box.Checkout(ctx, "user", memorybox.MainBranch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(main); got != "q1|a1" {
+		t.Fatalf("main = %q, want it untrimmed", got)
+	}
+}
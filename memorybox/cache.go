@@ -2,16 +2,86 @@ package memorybox
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"time"
 )
 
 // NewCache creates and returns a pointer to a new MemoryCache instance.
 // The cache uses an internal map to store keys and their associated values along with expiration metadata.
-func NewCache() *MemoryCache {
-	return &MemoryCache{
+// Without options the cache is unbounded and expired keys are only removed when they are read;
+// see WithJanitor, WithMaxEntries and WithMaxBytes.
+func NewCache(opts ...CacheOption) *MemoryCache {
+	c := &MemoryCache{
 		memory: make(map[string]MapFields),
+		stop:   make(chan struct{}),
+		clock:  SystemClock,
 	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	if c.maxEntries > 0 || c.maxBytes > 0 {
+		if c.eviction == nil {
+			c.eviction = LRUPolicy{}
+		}
+		c.order = newEvictionQueue(c.memory, c.eviction)
+	}
+	if c.janitorInterval > 0 {
+		// Create the ticker before returning, so a fake clock advanced right after NewCache sees it
+		ticker := c.clock.NewTicker(c.janitorInterval)
+		c.background.Add(1)
+		go c.janitor(ticker)
+	}
+	return c
+}
+
+// WithJanitor starts a background goroutine that removes expired keys every interval,
+// so abandoned conversations do not stay in memory forever. Call Close to stop it.
+func WithJanitor(interval time.Duration) CacheOption {
+	return func(c *MemoryCache) {
+		c.janitorInterval = interval
+	}
+}
+
+// Close stops the background janitor, if any. The cache stays usable after Close,
+// except for caches created by OpenCache, which write a final snapshot and must not be written afterwards.
+// It is safe to call Close more than once.
+func (c *MemoryCache) Close() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.stop)
+		c.background.Wait()
+		err = c.closePersistence()
+	})
+	return err
+}
+
+// janitor removes expired keys on every tick until Close is called.
+func (c *MemoryCache) janitor(ticker Ticker) {
+	defer c.background.Done()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			c.deleteExpired()
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// deleteExpired removes all expired keys from the cache.
+func (c *MemoryCache) deleteExpired() {
+	now := c.now()
+	c.mu.Lock()
+	for key, mf := range c.memory {
+		if mf.expired(now) {
+			c.expire(key)
+		}
+	}
+	c.unlock()
 }
 
 // Set stores a value in the cache with an optional expiration time.
@@ -20,52 +90,227 @@ func NewCache() *MemoryCache {
 // The value is converted to a string representation before being stored.
 // Context parameter is accepted for future extensibility but currently not used.
 func (c *MemoryCache) Set(ctx context.Context, key string, value any, expiration ...time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.set(key, value, expiration...)
+}
+
+// set stores a value without locking and evicts other keys if the cache is full.
+// KeepTTL keeps the expiration time of an existing, unexpired key. The caller must hold the write lock.
+func (c *MemoryCache) set(key string, value any, expiration ...time.Duration) error {
 	var expireTime time.Time
-	if len(expiration) > 0 && expiration[0] > 0 {
-		expireTime = time.Now().Add(expiration[0])
+	switch {
+	case len(expiration) == 0:
+	case expiration[0] == KeepTTL:
+		if mf, ok := c.memory[key]; ok && !mf.expired(c.now()) {
+			expireTime = mf.ExpireTime
+		}
+	case expiration[0] > 0:
+		expireTime = c.now().Add(expiration[0])
 	}
+	return c.store(key, fmt.Sprintf("%v", value), expireTime) // zero time means no TTL (time-to-live)
+}
 
-	c.mu.Lock()
-	c.memory[key] = MapFields{
-		Value:      fmt.Sprintf("%v", value),
-		ExpireTime: expireTime, // zero time means no TTL (time-to-live)
+// store writes an entry with an absolute expiration time, logging it first if the cache is persistent.
+// The caller must hold the write lock.
+func (c *MemoryCache) store(key string, value string, expireTime time.Time) error {
+	if err := c.persist(logRecord{Op: "set", Key: key, Value: value, Expire: expireTime}); err != nil {
+		return err
+	}
+
+	old, exists := c.memory[key]
+	if exists {
+		c.bytes -= entrySize(key, old)
+	}
+	mf := MapFields{
+		Value:      value,
+		ExpireTime: expireTime,
+		LastAccess: c.now(),
+		Hits:       old.Hits + 1,
+	}
+	c.memory[key] = mf
+	c.bytes += entrySize(key, mf)
+
+	if c.bounded() {
+		c.order.push(key)
+		c.evict(key)
 	}
-	c.mu.Unlock()
 	return nil
 }
 
+// remove deletes a key, logging the deletion if the cache is persistent.
+// The caller must hold the write lock.
+func (c *MemoryCache) remove(key string) error {
+	if _, ok := c.memory[key]; !ok {
+		return nil
+	}
+	if err := c.persist(logRecord{Op: "del", Key: key}); err != nil {
+		return err
+	}
+	c.drop(key)
+	return nil
+}
+
+// drop deletes a key and its accounting without logging. The caller must hold the write lock.
+func (c *MemoryCache) drop(key string) {
+	mf, ok := c.memory[key]
+	if !ok {
+		return
+	}
+	c.bytes -= entrySize(key, mf)
+	delete(c.memory, key)
+	if c.bounded() {
+		c.order.remove(key)
+	}
+}
+
+// expire removes a key whose TTL has passed. Expired keys are skipped on replay,
+// so the removal is not logged. The caller must hold the write lock.
+func (c *MemoryCache) expire(key string) {
+	mf := c.memory[key]
+	c.drop(key)
+	c.expirations++
+	if c.onExpire != nil {
+		value, _ := mf.Value.(string)
+		c.events = append(c.events, func() { c.onExpire(key, value) })
+	}
+}
+
+// unlock releases the write lock and then runs the callbacks queued while it was held,
+// so that callbacks may use the cache without deadlocking.
+func (c *MemoryCache) unlock() {
+	events := c.events
+	c.events = nil
+	c.mu.Unlock()
+	for _, event := range events {
+		event()
+	}
+}
+
+// expired reports whether the entry has a TTL that has passed at now.
+func (mf MapFields) expired(now time.Time) bool {
+	return !mf.ExpireTime.IsZero() && now.After(mf.ExpireTime)
+}
+
 // Get retrieves a value from the cache by key.
-// If the key is not found, it returns an error indicating so.
-// If the key exists but has expired, the key is deleted from the cache and an expiration error is returned.
+// If the key is not found, it returns ErrNotFound.
+// If the key exists but has expired, the key is deleted from the cache and ErrExpired is returned.
 // Otherwise, the cached string value is returned.
+// A bounded cache records the access for its eviction policy, so Get takes the write lock there.
 // Context parameter is accepted for future extensibility but currently not used.
 func (c *MemoryCache) Get(ctx context.Context, key string) (string, error) {
+	if c.bounded() {
+		c.mu.Lock()
+		defer c.unlock()
+		value, err := c.get(key)
+		if err == nil {
+			c.touch(key)
+		}
+		return value, err
+	}
+
 	c.mu.RLock()
 	mf, ok := c.memory[key]
 	if !ok {
 		c.mu.RUnlock()
-		return "", fmt.Errorf("key not found")
+		return "", ErrNotFound
 	}
 
 	// Check if the key has an expiration time and if it has passed
-	if !mf.ExpireTime.IsZero() && time.Now().After(mf.ExpireTime) {
+	if mf.expired(c.now()) {
 		c.mu.RUnlock()
 		// Need to delete, so acquire write lock
 		c.mu.Lock()
 		// Check again in case it was updated
-		if mf2, ok2 := c.memory[key]; ok2 && !mf2.ExpireTime.IsZero() && time.Now().After(mf2.ExpireTime) {
-			delete(c.memory, key) // Remove expired key
+		if mf2, ok2 := c.memory[key]; ok2 && mf2.expired(c.now()) {
+			c.expire(key) // Remove expired key
 		}
-		c.mu.Unlock()
-		return "", fmt.Errorf("key expired")
+		c.unlock()
+		return "", ErrExpired
 	}
 
 	value, ok := mf.Value.(string)
 	if !ok {
 		c.mu.RUnlock()
-		return "", fmt.Errorf("invalid value type")
+		return "", fmt.Errorf("%w: value of %q is not a string", ErrCorrupted, key)
 	}
 
 	c.mu.RUnlock()
 	return value, nil
 }
+
+// get returns the value of key, removing it if it has expired. The caller must hold the write lock.
+func (c *MemoryCache) get(key string) (string, error) {
+	mf, ok := c.memory[key]
+	if !ok {
+		return "", ErrNotFound
+	}
+	if mf.expired(c.now()) {
+		c.expire(key) // Remove expired key
+		return "", ErrExpired
+	}
+	value, ok := mf.Value.(string)
+	if !ok {
+		return "", fmt.Errorf("%w: value of %q is not a string", ErrCorrupted, key)
+	}
+	return value, nil
+}
+
+// Update atomically reads the value stored under key, passes it to fn and stores the result.
+// The write lock is held for the whole operation, so concurrent updates of the same key are serialized.
+// Expired keys are reported to fn as missing.
+// Context parameter is accepted for future extensibility but currently not used.
+func (c *MemoryCache) Update(ctx context.Context, key string, fn func(old string, exists bool) (string, error), expiration ...time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	old, err := c.get(key)
+	exists := err == nil
+	if errors.Is(err, ErrCorrupted) {
+		return err
+	}
+
+	value, err := fn(old, exists)
+	if err != nil {
+		return err
+	}
+	return c.set(key, value, expiration...)
+}
+
+// Expire sets the TTL of an existing key; zero or less removes the TTL.
+// Expiring a missing or expired key is not an error.
+// Context parameter is accepted for future extensibility but currently not used.
+func (c *MemoryCache) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	value, err := c.get(key)
+	if errors.Is(err, ErrCorrupted) {
+		return err
+	}
+	if err != nil {
+		return nil
+	}
+	return c.set(key, value, expiration)
+}
+
+// GetEx retrieves a value like Get and sets its TTL like Expire, e.g. to extend a conversation on every read.
+// Context parameter is accepted for future extensibility but currently not used.
+func (c *MemoryCache) GetEx(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	c.mu.Lock()
+	defer c.unlock()
+
+	value, err := c.get(key)
+	if err != nil {
+		return "", err
+	}
+	return value, c.set(key, value, expiration)
+}
+
+// Delete removes a key from the cache. Deleting a missing key is not an error.
+// Context parameter is accepted for future extensibility but currently not used.
+func (c *MemoryCache) Delete(ctx context.Context, key string) error {
+	c.mu.Lock()
+	defer c.unlock()
+	return c.remove(key)
+}
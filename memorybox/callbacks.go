@@ -0,0 +1,21 @@
+package memorybox
+
+// WithOnExpire registers fn to be called with the key and the last value of every entry
+// removed because its TTL passed, e.g. to archive a conversation or summarize it into long-term memory.
+// Expired entries are noticed on access or by the janitor (see WithJanitor);
+// entries of a persistent cache that expire while the process is down are not reported.
+// fn runs after the cache lock is released, in the goroutine that noticed the expiry.
+func WithOnExpire(fn func(key, value string)) CacheOption {
+	return func(c *MemoryCache) {
+		c.onExpire = fn
+	}
+}
+
+// WithOnEvict registers fn to be called with the key and the last value of every entry
+// dropped because the cache exceeded WithMaxEntries or WithMaxBytes.
+// fn runs after the cache lock is released, in the goroutine whose write caused the eviction.
+func WithOnEvict(fn func(key, value string)) CacheOption {
+	return func(c *MemoryCache) {
+		c.onEvict = fn
+	}
+}
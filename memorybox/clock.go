@@ -0,0 +1,58 @@
+package memorybox
+
+import "time"
+
+// Clock is the source of time for MemoryCache and MemoryBox.
+// The system clock is used by default; inject a fake one, e.g. from the clocktest package,
+// to test expiry, janitor sweeps and checkpoints without real sleeps.
+type Clock interface {
+	// Now returns the current time.
+	Now() time.Time
+
+	// NewTicker returns a ticker that delivers ticks every d, like time.NewTicker.
+	NewTicker(d time.Duration) Ticker
+}
+
+// Ticker delivers ticks on a channel until it is stopped.
+type Ticker interface {
+	// C returns the channel on which the ticks are delivered.
+	C() <-chan time.Time
+
+	// Stop turns off the ticker. No more ticks are sent after Stop returns.
+	Stop()
+}
+
+// SystemClock is the Clock backed by the time package.
+var SystemClock Clock = systemClock{}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+type systemTicker struct {
+	*time.Ticker
+}
+
+func (t systemTicker) C() <-chan time.Time { return t.Ticker.C }
+
+// WithClock makes the cache read the time from clock instead of the system clock.
+func WithClock(clock Clock) CacheOption {
+	return func(c *MemoryCache) {
+		c.clock = clock
+	}
+}
+
+// now returns the current time of the cache clock.
+func (c *MemoryCache) now() time.Time {
+	return c.clock.Now()
+}
+
+// clock returns the configured Clock or SystemClock.
+func (b *MemoryBox) clock() Clock {
+	if b.Clock != nil {
+		return b.Clock
+	}
+	return SystemClock
+}
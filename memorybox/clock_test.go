@@ -0,0 +1,72 @@
+package memorybox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+	"github.com/rmay1er/magic-memory-box-go/memorybox/clocktest"
+)
+
+func TestCacheExpiryWithFakeClock(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	cache := memorybox.NewCache(memorybox.WithClock(clock))
+
+	cache.Set(ctx, "user", "hi", 30*time.Second)
+	clock.Advance(29 * time.Second)
+	if v, err := cache.Get(ctx, "user"); err != nil || v != "hi" {
+		t.Fatalf("Get before expiry = %q, %v", v, err)
+	}
+	clock.Advance(2 * time.Second)
+	if _, err := cache.Get(ctx, "user"); !errors.Is(err, memorybox.ErrExpired) {
+		t.Fatalf("Get after expiry error = %v, want ErrExpired", err)
+	}
+}
+
+func TestJanitorWithFakeClock(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	expired := make(chan string, 1)
+	cache := memorybox.NewCache(
+		memorybox.WithClock(clock),
+		memorybox.WithJanitor(time.Minute),
+		memorybox.WithOnExpire(func(key, value string) { expired <- key + "=" + value }),
+	)
+	defer cache.Close()
+
+	cache.Set(ctx, "user", "hi", 30*time.Second)
+	cache.Set(ctx, "other", "kept", time.Hour)
+	clock.Advance(time.Minute)
+
+	select {
+	case got := <-expired:
+		if got != "user=hi" {
+			t.Fatalf("expired %s, want user=hi", got)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("janitor did not remove the expired key")
+	}
+	if v, err := cache.Get(ctx, "other"); err != nil || v != "kept" {
+		t.Fatalf("Get(other) = %q, %v", v, err)
+	}
+}
+
+func TestMemoryBoxClock(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := clocktest.New(start)
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{Clock: clock})
+
+	ctx := context.Background()
+	box.Tell(ctx, "user", "q1")
+	clock.Advance(time.Minute)
+	msgs, err := box.Remember(ctx, "user", "a1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !msgs[0].CreatedAt.Equal(start) || !msgs[1].CreatedAt.Equal(start.Add(time.Minute)) {
+		t.Fatalf("CreatedAt = %v, %v, want the fake clock time", msgs[0].CreatedAt, msgs[1].CreatedAt)
+	}
+}
@@ -0,0 +1,141 @@
+// Package clocktest provides a fake memorybox.Clock for tests of expiry, janitor sweeps and checkpoints.
+//
+//	clock := clocktest.New(time.Now())
+//	cache := memorybox.NewCache(memorybox.WithClock(clock), memorybox.WithJanitor(time.Minute))
+//	cache.Set(ctx, "user", "hi", 30*time.Second)
+//	clock.Advance(31 * time.Second) // "user" is expired now, no sleeping needed
+package clocktest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// Clock is a memorybox.Clock whose time only moves when Advance is called.
+// It is safe for concurrent use.
+type Clock struct {
+	advance sync.Mutex // Serializes Advance calls.
+
+	mu      sync.Mutex
+	cond    *sync.Cond // Signalled when tickers are added, for BlockUntil.
+	now     time.Time
+	tickers []*ticker
+}
+
+// New returns a fake clock set to start.
+func New(start time.Time) *Clock {
+	c := &Clock{now: start}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+// Now returns the current fake time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+// NewTicker returns a ticker that fires every d of fake time. It panics if d is not positive, like time.NewTicker.
+func (c *Clock) NewTicker(d time.Duration) memorybox.Ticker {
+	if d <= 0 {
+		panic("clocktest: non-positive interval for NewTicker")
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &ticker{
+		clock:  c,
+		c:      make(chan time.Time),
+		done:   make(chan struct{}),
+		period: d,
+		next:   c.now.Add(d),
+	}
+	c.tickers = append(c.tickers, t)
+	c.cond.Broadcast()
+	return t
+}
+
+// Advance moves the clock forward by d and fires every ticker that becomes due, in time order.
+// Unlike a real ticker, no tick is dropped: Advance returns once each tick has been received
+// or its ticker stopped. So after Advance returns, the janitor has started the sweeps for this period
+// and finished the previous ones; wait for WithOnExpire or poll Stats to see a sweep complete.
+// Do not call Advance from the goroutine that receives the ticks, e.g. from an expiry callback.
+func (c *Clock) Advance(d time.Duration) {
+	c.advance.Lock()
+	defer c.advance.Unlock()
+
+	c.mu.Lock()
+	end := c.now.Add(d)
+	for {
+		var due *ticker
+		for _, t := range c.tickers {
+			if !t.next.After(end) && (due == nil || t.next.Before(due.next)) {
+				due = t
+			}
+		}
+		if due == nil {
+			break
+		}
+		c.now = due.next
+		due.next = due.next.Add(due.period)
+
+		at := c.now
+		c.mu.Unlock()
+		due.send(at)
+		c.mu.Lock()
+	}
+	c.now = end
+	c.mu.Unlock()
+}
+
+// BlockUntil waits until at least n tickers are running.
+// Use it before Advance when a ticker is created in another goroutine, e.g. by RememberChunks.
+func (c *Clock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.tickers) < n {
+		c.cond.Wait()
+	}
+}
+
+// ticker is a memorybox.Ticker driven by a fake Clock.
+type ticker struct {
+	clock    *Clock
+	c        chan time.Time
+	done     chan struct{}
+	stopOnce sync.Once
+	period   time.Duration
+	next     time.Time // Fake time of the next tick; guarded by clock.mu.
+}
+
+// C returns the channel on which the ticks are delivered.
+func (t *ticker) C() <-chan time.Time {
+	return t.c
+}
+
+// Stop turns off the ticker. A tick that is being delivered is abandoned.
+func (t *ticker) Stop() {
+	t.stopOnce.Do(func() {
+		close(t.done)
+
+		t.clock.mu.Lock()
+		defer t.clock.mu.Unlock()
+		for i, other := range t.clock.tickers {
+			if other == t {
+				t.clock.tickers = append(t.clock.tickers[:i], t.clock.tickers[i+1:]...)
+				break
+			}
+		}
+	})
+}
+
+// send delivers a tick unless the ticker is stopped first.
+func (t *ticker) send(at time.Time) {
+	select {
+	case t.c <- at:
+	case <-t.done:
+	}
+}
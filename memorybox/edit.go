@@ -0,0 +1,50 @@
+package memorybox
+
+import "context"
+
+// PopReply removes the answer to the last user message: the assistant reply together with
+// any tool calls and tool results that led to it. The remaining history ends with the user
+// message and can be sent to the model again to regenerate the answer.
+// It returns ErrNoReply if the history does not end with an assistant or tool message.
+func (b *MemoryBox) PopReply(ctx context.Context, userid string) ([]Message, error) {
+	return b.update(ctx, userid, func(data []Message) ([]Message, error) {
+		i := len(data)
+		for i > 0 && (data[i-1].Role == AssistantRole || data[i-1].Role == ToolRole) {
+			i--
+		}
+		if i == len(data) {
+			return data, ErrNoReply
+		}
+		return data[:i], nil
+	})
+}
+
+// EditMessage replaces the content of the message with the given ID, e.g. when the user edits a prompt.
+// The message keeps its ID, unless it is shared with other branches of the conversation: the active
+// branch then gets an edited copy with a new ID and the other branches keep the original.
+// Combine it with TruncateAfter to drop the answers to the old content.
+// It returns ErrMessageNotFound if there is no such message.
+func (b *MemoryBox) EditMessage(ctx context.Context, userid string, messageID string, content string) ([]Message, error) {
+	return b.update(ctx, userid, func(data []Message) ([]Message, error) {
+		for i, m := range data {
+			if m.ID == messageID {
+				data[i].Content = content
+				return data, nil
+			}
+		}
+		return data, ErrMessageNotFound
+	})
+}
+
+// TruncateAfter removes every message that follows the message with the given ID.
+// It returns ErrMessageNotFound if there is no such message.
+func (b *MemoryBox) TruncateAfter(ctx context.Context, userid string, messageID string) ([]Message, error) {
+	return b.update(ctx, userid, func(data []Message) ([]Message, error) {
+		for i, m := range data {
+			if m.ID == messageID {
+				return data[:i+1], nil
+			}
+		}
+		return data, ErrMessageNotFound
+	})
+}
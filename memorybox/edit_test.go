@@ -0,0 +1,90 @@
+package memorybox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+func TestEditMessageByIDInTrimmedView(t *testing.T) {
+	ctx := context.Background()
+	// Trimmed messages wait in the store for the summary, so the stored history
+	// is longer than the view GetMemories returns.
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{
+		ContextLenSize: 2,
+		Summarizer:     (&fakeSummarizer{}).summarize,
+		SummaryBatch:   10,
+	})
+	for _, text := range []string{"first question", "first answer", "second question", "second answer"} {
+		box.Tell(ctx, "user", text)
+	}
+
+	view, err := box.GetMemories(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	target := view[0]
+	if target.Content != "second question" {
+		t.Fatalf("first visible message = %q, want %q", target.Content, "second question")
+	}
+
+	if _, err := box.EditMessage(ctx, "user", target.ID, "edited"); err != nil {
+		t.Fatal(err)
+	}
+	view, err = box.GetMemories(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if view[0].ID != target.ID || view[0].Content != "edited" {
+		t.Fatalf("first visible message = %q (%s), want the edited %s", view[0].Content, view[0].ID, target.ID)
+	}
+
+	if _, err := box.EditMessage(ctx, "user", "no-such-id", "x"); !errors.Is(err, memorybox.ErrMessageNotFound) {
+		t.Fatalf("EditMessage(unknown) error = %v, want ErrMessageNotFound", err)
+	}
+}
+
+func TestOldFormatHistoryHasStableIDs(t *testing.T) {
+	ctx := context.Background()
+	cache := memorybox.NewCache()
+	box := memorybox.NewMemoryBox(cache, memorybox.MemoryBoxConfig{})
+	// Written before messages had json tags and IDs; "ok" appears twice
+	old := `[{"Role":"user","Content":"q1"},{"Role":"assistant","Content":"ok"},{"Role":"user","Content":"q2"},{"Role":"assistant","Content":"ok"}]`
+	cache.Set(ctx, "user", old)
+
+	first, err := box.GetMemories(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, _ := box.GetMemories(ctx, "user")
+	for i := range first {
+		if first[i].ID == "" || first[i].ID != second[i].ID {
+			t.Fatalf("message %d has IDs %q and %q on two reads", i, first[i].ID, second[i].ID)
+		}
+	}
+	if first[1].ID == first[3].ID {
+		t.Fatal("identical messages share an ID")
+	}
+
+	if _, err := box.EditMessage(ctx, "user", first[2].ID, "q2 edited"); err != nil {
+		t.Fatal(err)
+	}
+	msgs, err := box.DeleteMessage(ctx, "user", first[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(msgs); got != "q1|q2 edited|ok" {
+		t.Fatalf("history = %s, want q1|q2 edited|ok", got)
+	}
+
+	cache.Set(ctx, "other", old)
+	view, _ := box.GetMemories(ctx, "other")
+	if _, err := box.TruncateAfter(ctx, "other", view[1].ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := box.Fork(ctx, "other", view[0].ID); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,40 @@
+package memorybox
+
+import "errors"
+
+var (
+	// ErrNotFound is returned by every IMemorizer when a key does not exist.
+	// MemoryBox treats it as an empty history; any other error from the store is propagated.
+	ErrNotFound = errors.New("key not found")
+
+	// ErrExpired is returned when a key exists but its TTL has passed.
+	// It matches ErrNotFound as well, so errors.Is(err, ErrNotFound) covers both.
+	ErrExpired error = expiredError{}
+
+	// ErrCorrupted is returned when a stored value cannot be read or decoded.
+	ErrCorrupted = errors.New("corrupted value")
+
+	// ErrInvalidUserID is returned by the session methods for user IDs that contain the ASCII unit
+	// separator "\x1f", which separates the user ID in session keys. Plain histories accept any user ID.
+	ErrInvalidUserID = errors.New("invalid user ID")
+
+	// ErrSessionNotFound is returned when a session does not exist for the user.
+	ErrSessionNotFound = errors.New("session not found")
+
+	// ErrMessageNotFound is returned when a message with the given ID is not in the history.
+	ErrMessageNotFound = errors.New("message not found")
+
+	// ErrBranchNotFound is returned when a conversation has no branch with the given ID.
+	ErrBranchNotFound = errors.New("branch not found")
+
+	// ErrNoReply is returned by PopReply when the history does not end with an assistant reply.
+	ErrNoReply = errors.New("no assistant reply to pop")
+)
+
+// expiredError is the type of ErrExpired.
+type expiredError struct{}
+
+func (expiredError) Error() string { return "key expired" }
+
+// Is makes ErrExpired match ErrNotFound.
+func (expiredError) Is(target error) bool { return target == ErrNotFound }
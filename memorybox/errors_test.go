@@ -0,0 +1,71 @@
+package memorybox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+	"github.com/rmay1er/magic-memory-box-go/memorybox/clocktest"
+)
+
+func TestCacheSentinelErrors(t *testing.T) {
+	for _, tt := range []struct {
+		name  string
+		store func(memorybox.Clock) memorybox.IMemorizer
+	}{
+		{"cache", func(c memorybox.Clock) memorybox.IMemorizer { return memorybox.NewCache(memorybox.WithClock(c)) }},
+		{"sharded", func(c memorybox.Clock) memorybox.IMemorizer {
+			return memorybox.NewShardedCache(4, memorybox.WithClock(c))
+		}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clock := clocktest.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+			store := tt.store(clock)
+
+			_, err := store.Get(ctx, "missing")
+			if !errors.Is(err, memorybox.ErrNotFound) || errors.Is(err, memorybox.ErrExpired) {
+				t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+			}
+
+			store.Set(ctx, "user", "hi", time.Minute)
+			clock.Advance(2 * time.Minute)
+			_, err = store.Get(ctx, "user")
+			if !errors.Is(err, memorybox.ErrExpired) || !errors.Is(err, memorybox.ErrNotFound) {
+				t.Fatalf("Get(expired) error = %v, want ErrExpired matching ErrNotFound", err)
+			}
+		})
+	}
+}
+
+func TestMemoryBoxCorrupted(t *testing.T) {
+	ctx := context.Background()
+	cache := memorybox.NewCache()
+	box := memorybox.NewMemoryBox(cache, memorybox.MemoryBoxConfig{})
+
+	cache.Set(ctx, "user", "{not json")
+	if _, err := box.GetMemories(ctx, "user"); !errors.Is(err, memorybox.ErrCorrupted) {
+		t.Fatalf("GetMemories error = %v, want ErrCorrupted", err)
+	}
+	if _, err := box.Tell(ctx, "user", "hi"); !errors.Is(err, memorybox.ErrCorrupted) {
+		t.Fatalf("Tell error = %v, want ErrCorrupted", err)
+	}
+	if v, _ := cache.Get(ctx, "user"); v != "{not json" {
+		t.Fatalf("corrupted history was overwritten with %q", v)
+	}
+
+	cache.Set(ctx, "user\x1fsessions", "{not json") // The session index key
+	if _, err := box.ListSessions(ctx, "user"); !errors.Is(err, memorybox.ErrCorrupted) {
+		t.Fatalf("ListSessions error = %v, want ErrCorrupted", err)
+	}
+
+	// A missing history is not an error for writes, only for reads
+	if _, err := box.GetMemories(ctx, "other"); !errors.Is(err, memorybox.ErrNotFound) {
+		t.Fatalf("GetMemories(other) error = %v, want ErrNotFound", err)
+	}
+	if _, err := box.Tell(ctx, "other", "hi"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,204 @@
+package memorybox
+
+import (
+	"container/heap"
+	"log/slog"
+)
+
+// EvictionPolicy chooses which conversation a bounded MemoryCache drops when it is full.
+type EvictionPolicy interface {
+	// Less reports whether a should be evicted before b.
+	Less(a, b MapFields) bool
+}
+
+// LRUPolicy evicts the least recently used key first. It is the default policy.
+type LRUPolicy struct{}
+
+// Less reports whether a was used less recently than b.
+func (LRUPolicy) Less(a, b MapFields) bool {
+	return a.LastAccess.Before(b.LastAccess)
+}
+
+// LFUPolicy evicts the least frequently used key first; ties are broken by recency.
+type LFUPolicy struct{}
+
+// Less reports whether a was used less often than b.
+func (LFUPolicy) Less(a, b MapFields) bool {
+	if a.Hits != b.Hits {
+		return a.Hits < b.Hits
+	}
+	return a.LastAccess.Before(b.LastAccess)
+}
+
+// NearestExpiryPolicy evicts the key that would expire soonest first.
+// Keys without a TTL are evicted last, least recently used first.
+type NearestExpiryPolicy struct{}
+
+// Less reports whether a expires before b.
+func (NearestExpiryPolicy) Less(a, b MapFields) bool {
+	switch {
+	case a.ExpireTime.IsZero() && b.ExpireTime.IsZero():
+		return a.LastAccess.Before(b.LastAccess)
+	case a.ExpireTime.IsZero():
+		return false
+	case b.ExpireTime.IsZero():
+		return true
+	}
+	return a.ExpireTime.Before(b.ExpireTime)
+}
+
+// CacheStats is a snapshot of MemoryCache counters.
+type CacheStats struct {
+	Entries     int    // Number of keys currently stored
+	Bytes       int64  // Total size of keys and values currently stored
+	Evictions   uint64 // Keys dropped because the cache was full
+	Expirations uint64 // Keys removed because their TTL passed
+}
+
+// WithMaxEntries bounds the number of keys in the cache. When a write exceeds the limit,
+// keys are evicted according to the eviction policy (LRU unless WithEvictionPolicy is used).
+func WithMaxEntries(n int) CacheOption {
+	return func(c *MemoryCache) {
+		c.maxEntries = n
+	}
+}
+
+// WithMaxBytes bounds the total size of keys and values in the cache.
+// The key just written is never evicted, even if it alone exceeds the budget.
+func WithMaxBytes(n int64) CacheOption {
+	return func(c *MemoryCache) {
+		c.maxBytes = n
+	}
+}
+
+// WithEvictionPolicy sets the policy used by WithMaxEntries and WithMaxBytes.
+func WithEvictionPolicy(p EvictionPolicy) CacheOption {
+	return func(c *MemoryCache) {
+		c.eviction = p
+	}
+}
+
+// Stats returns the current cache counters.
+func (c *MemoryCache) Stats() CacheStats {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return CacheStats{
+		Entries:     len(c.memory),
+		Bytes:       c.bytes,
+		Evictions:   c.evictions,
+		Expirations: c.expirations,
+	}
+}
+
+// bounded reports whether the cache has a size limit and tracks access order.
+func (c *MemoryCache) bounded() bool {
+	return c.order != nil
+}
+
+// touch records an access to key for the eviction policy. The caller must hold the write lock.
+func (c *MemoryCache) touch(key string) {
+	mf := c.memory[key]
+	mf.LastAccess = c.now()
+	mf.Hits++
+	c.memory[key] = mf
+	c.order.fix(key)
+}
+
+// evict drops keys until the cache fits its limits again. keep, the key just written, is never evicted.
+// The caller must hold the write lock.
+func (c *MemoryCache) evict(keep string) {
+	over := func() bool {
+		return (c.maxEntries > 0 && len(c.memory) > c.maxEntries) ||
+			(c.maxBytes > 0 && c.bytes > c.maxBytes)
+	}
+	if !over() {
+		return
+	}
+
+	// Take the new key out of the queue so that it can not be chosen.
+	c.order.remove(keep)
+	for over() && c.order.Len() > 0 {
+		victim := c.order.keys[0]
+		value, _ := c.memory[victim].Value.(string)
+		if err := c.remove(victim); err != nil {
+			// The eviction could not be logged; drop the key anyway to respect the limits.
+			slog.Error("memorybox: log eviction", "key", victim, "err", err)
+			c.drop(victim)
+		}
+		c.evictions++
+		if c.onEvict != nil {
+			c.events = append(c.events, func() { c.onEvict(victim, value) })
+		}
+	}
+	c.order.push(keep)
+}
+
+// entrySize returns the number of bytes a key and its value occupy.
+func entrySize(key string, mf MapFields) int64 {
+	value, _ := mf.Value.(string)
+	return int64(len(key) + len(value))
+}
+
+// evictionQueue is a heap of keys ordered by the eviction policy, the next victim first.
+type evictionQueue struct {
+	keys   []string
+	index  map[string]int
+	memory map[string]MapFields
+	policy EvictionPolicy
+}
+
+func newEvictionQueue(memory map[string]MapFields, policy EvictionPolicy) *evictionQueue {
+	return &evictionQueue{
+		index:  make(map[string]int),
+		memory: memory,
+		policy: policy,
+	}
+}
+
+func (q *evictionQueue) Len() int { return len(q.keys) }
+
+func (q *evictionQueue) Less(i, j int) bool {
+	return q.policy.Less(q.memory[q.keys[i]], q.memory[q.keys[j]])
+}
+
+func (q *evictionQueue) Swap(i, j int) {
+	q.keys[i], q.keys[j] = q.keys[j], q.keys[i]
+	q.index[q.keys[i]] = i
+	q.index[q.keys[j]] = j
+}
+
+func (q *evictionQueue) Push(x any) {
+	key := x.(string)
+	q.index[key] = len(q.keys)
+	q.keys = append(q.keys, key)
+}
+
+func (q *evictionQueue) Pop() any {
+	key := q.keys[len(q.keys)-1]
+	q.keys = q.keys[:len(q.keys)-1]
+	delete(q.index, key)
+	return key
+}
+
+// push adds key to the queue or moves it to its new position.
+func (q *evictionQueue) push(key string) {
+	if _, ok := q.index[key]; ok {
+		q.fix(key)
+		return
+	}
+	heap.Push(q, key)
+}
+
+// fix restores the heap order after the entry of key changed.
+func (q *evictionQueue) fix(key string) {
+	if i, ok := q.index[key]; ok {
+		heap.Fix(q, i)
+	}
+}
+
+// remove drops key from the queue.
+func (q *evictionQueue) remove(key string) {
+	if i, ok := q.index[key]; ok {
+		heap.Remove(q, i)
+	}
+}
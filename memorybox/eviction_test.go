@@ -0,0 +1,88 @@
+package memorybox_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+	"github.com/rmay1er/magic-memory-box-go/memorybox/clocktest"
+)
+
+func TestBoundedCacheEviction(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  memorybox.CacheOption
+		policy memorybox.EvictionPolicy
+		victim string
+	}{
+		{"entries lru", memorybox.WithMaxEntries(3), memorybox.LRUPolicy{}, "a"},
+		{"entries lfu", memorybox.WithMaxEntries(3), memorybox.LFUPolicy{}, "c"},
+		{"entries expiry", memorybox.WithMaxEntries(3), memorybox.NearestExpiryPolicy{}, "b"},
+		{"bytes lru", memorybox.WithMaxBytes(24), memorybox.LRUPolicy{}, "a"},
+		{"bytes lfu", memorybox.WithMaxBytes(24), memorybox.LFUPolicy{}, "c"},
+		{"bytes expiry", memorybox.WithMaxBytes(24), memorybox.NearestExpiryPolicy{}, "b"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			clock := clocktest.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+			var evicted []string
+			cache := memorybox.NewCache(
+				memorybox.WithClock(clock),
+				tt.limit,
+				memorybox.WithEvictionPolicy(tt.policy),
+				memorybox.WithOnEvict(func(key, value string) { evicted = append(evicted, key+"="+value) }),
+			)
+			step := func(op func()) {
+				op()
+				clock.Advance(time.Second)
+			}
+
+			// Every entry is 8 bytes. After these steps a was used least recently, c has the fewest uses
+			// among the oldest and b expires first.
+			step(func() { cache.Set(ctx, "a", "value-a", time.Hour) })
+			step(func() { cache.Set(ctx, "b", "value-b", 10*time.Minute) })
+			step(func() { cache.Set(ctx, "c", "value-c") })
+			step(func() { cache.Get(ctx, "a") })
+			step(func() { cache.Get(ctx, "a") })
+			step(func() { cache.Get(ctx, "c") })
+			step(func() { cache.Get(ctx, "b") })
+			// d expires before all others, but the key just written is never evicted.
+			step(func() { cache.Set(ctx, "d", "value-d", time.Second) })
+
+			if want := tt.victim + "=value-" + tt.victim; strings.Join(evicted, ",") != want {
+				t.Fatalf("evicted %v, want %s", evicted, want)
+			}
+			wantMissing(t, cache, tt.victim)
+			wantValue(t, cache, "d", "value-d")
+			stats := cache.Stats()
+			if stats.Entries != 3 || stats.Bytes != 24 || stats.Evictions != 1 {
+				t.Fatalf("Stats = %+v, want 3 entries, 24 bytes, 1 eviction", stats)
+			}
+		})
+	}
+}
+
+func TestBoundedCacheKeepsNewKey(t *testing.T) {
+	ctx := context.Background()
+	var evicted []string
+	cache := memorybox.NewCache(
+		memorybox.WithMaxBytes(10),
+		memorybox.WithOnEvict(func(key, value string) { evicted = append(evicted, key) }),
+	)
+
+	cache.Set(ctx, "a", "1")
+	cache.Set(ctx, "b", "2")
+	big := strings.Repeat("x", 100)
+	cache.Set(ctx, "big", big)
+
+	wantValue(t, cache, "big", big)
+	if strings.Join(evicted, ",") != "a,b" {
+		t.Fatalf("evicted %v, want a,b", evicted)
+	}
+	if stats := cache.Stats(); stats.Entries != 1 || stats.Bytes != 103 || stats.Evictions != 2 {
+		t.Fatalf("Stats = %+v, want 1 entry, 103 bytes, 2 evictions", stats)
+	}
+}
@@ -0,0 +1,34 @@
+package memorybox
+
+import (
+	"context"
+	"errors"
+)
+
+// Generator produces the reply to a conversation, e.g. by calling a model.
+// It receives the history including the new user message and returns the assistant reply
+// together with any tool call and tool result messages in the order they happened.
+type Generator func(ctx context.Context, msgs []Message) ([]Message, error)
+
+// Exchange runs one conversation turn transactionally. The user message is only staged:
+// generate gets the prospective history, and the user message is persisted together with
+// the reply messages in a single atomic write only if generate succeeds.
+// A failed or cancelled generation leaves the history untouched, so the next turn
+// does not send two user messages in a row.
+func (b *MemoryBox) Exchange(ctx context.Context, userid string, input string, generate Generator) ([]Message, error) {
+	history, err := b.GetMemories(ctx, userid)
+	if errors.Is(err, ErrNotFound) {
+		// A missing history is the start of a new conversation
+		history = []Message{}
+	} else if err != nil {
+		return nil, err
+	}
+
+	userMsg := b.newMessage(UserRole, input)
+	reply, err := generate(ctx, b.trim(append(history, userMsg)))
+	if err != nil {
+		return nil, err
+	}
+
+	return b.push(ctx, userid, append([]Message{userMsg}, reply...)...)
+}
@@ -0,0 +1,87 @@
+package memorybox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// countingStore counts the writes that reach the cache.
+type countingStore struct {
+	*memorybox.MemoryCache
+	writes int
+}
+
+func (s *countingStore) Set(ctx context.Context, key string, value any, expiration ...time.Duration) error {
+	s.writes++
+	return s.MemoryCache.Set(ctx, key, value, expiration...)
+}
+
+func (s *countingStore) Update(ctx context.Context, key string, fn func(old string, exists bool) (string, error), expiration ...time.Duration) error {
+	s.writes++
+	return s.MemoryCache.Update(ctx, key, fn, expiration...)
+}
+
+func TestExchangeFailureLeavesHistory(t *testing.T) {
+	ctx := context.Background()
+	store := &countingStore{MemoryCache: memorybox.NewCache()}
+	box := memorybox.NewMemoryBox(store, memorybox.MemoryBoxConfig{})
+	box.Tell(ctx, "user", "q1")
+	box.Remember(ctx, "user", "a1")
+	writes := store.writes
+
+	failure := errors.New("model unavailable")
+	var seen []memorybox.Message
+	_, err := box.Exchange(ctx, "user", "q2", func(ctx context.Context, msgs []memorybox.Message) ([]memorybox.Message, error) {
+		seen = msgs
+		return nil, failure
+	})
+	if !errors.Is(err, failure) {
+		t.Fatalf("Exchange error = %v, want the generator error", err)
+	}
+	if got := history(seen); got != "q1|a1|q2" {
+		t.Fatalf("generator saw %s, want q1|a1|q2", got)
+	}
+	if store.writes != writes {
+		t.Fatalf("failed Exchange wrote %d times", store.writes-writes)
+	}
+	msgs, _ := box.GetMemories(ctx, "user")
+	if got := history(msgs); got != "q1|a1" {
+		t.Fatalf("history = %s, want q1|a1 without the orphaned question", got)
+	}
+}
+
+func TestExchangeStoresTurnInOneWrite(t *testing.T) {
+	ctx := context.Background()
+	store := &countingStore{MemoryCache: memorybox.NewCache()}
+	box := memorybox.NewMemoryBox(store, memorybox.MemoryBoxConfig{})
+
+	msgs, err := box.Exchange(ctx, "user", "weather?", func(ctx context.Context, msgs []memorybox.Message) ([]memorybox.Message, error) {
+		return []memorybox.Message{
+			{Role: memorybox.AssistantRole, ToolCalls: []memorybox.ToolCall{{ID: "c1", Name: "weather"}}},
+			{Role: memorybox.ToolRole, Content: "sunny", ToolCallID: "c1"},
+			{Role: memorybox.AssistantRole, Content: "It is sunny."},
+		}, nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if store.writes != 1 {
+		t.Fatalf("Exchange wrote %d times, want 1", store.writes)
+	}
+	if got := history(msgs); got != "weather?||sunny|It is sunny." {
+		t.Fatalf("history = %s", got)
+	}
+	stored, _ := box.GetMemories(ctx, "user")
+	if len(stored) != 4 || stored[1].ToolCalls[0].ID != "c1" || stored[2].ToolCallID != "c1" {
+		t.Fatalf("stored history = %+v", stored)
+	}
+	for _, m := range stored {
+		if m.ID == "" {
+			t.Fatalf("stored message %q has no ID", m.Content)
+		}
+	}
+}
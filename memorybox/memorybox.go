@@ -3,7 +3,10 @@ package memorybox
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"io"
 	"log/slog"
+	"strings"
 	"time"
 )
 
@@ -13,6 +16,27 @@ type IMemoryBox interface {
 	TellUnsafe(ctx context.Context, userid string, value string) []Message
 	Remember(ctx context.Context, userid string, value string) ([]Message, error)
 	GetMemories(ctx context.Context, userid string) ([]Message, error)
+	AddMessage(ctx context.Context, userid string, msg Message) ([]Message, error)
+	Forget(ctx context.Context, userid string) error
+	DeleteMessage(ctx context.Context, userid string, messageID string) ([]Message, error)
+	PopReply(ctx context.Context, userid string) ([]Message, error)
+	EditMessage(ctx context.Context, userid string, messageID string, content string) ([]Message, error)
+	TruncateAfter(ctx context.Context, userid string, messageID string) ([]Message, error)
+	Fork(ctx context.Context, userid string, fromMessageID string) (Branch, error)
+	ListBranches(ctx context.Context, userid string) ([]Branch, error)
+	Checkout(ctx context.Context, userid string, branchID string) ([]Message, error)
+	Exchange(ctx context.Context, userid string, input string, generate Generator) ([]Message, error)
+	RememberStream(ctx context.Context, userid string, r io.Reader) ([]Message, error)
+	RememberChunks(ctx context.Context, userid string, chunks <-chan string) ([]Message, error)
+
+	CreateSession(ctx context.Context, userid string, title string) (Session, error)
+	ListSessions(ctx context.Context, userid string) ([]Session, error)
+	DeleteSession(ctx context.Context, userid string, sessionID string) error
+	AddRawSession(ctx context.Context, userid string, sessionID string, role Role, value string) ([]Message, error)
+	AddMessageSession(ctx context.Context, userid string, sessionID string, msg Message) ([]Message, error)
+	TellSession(ctx context.Context, userid string, sessionID string, value string) ([]Message, error)
+	RememberSession(ctx context.Context, userid string, sessionID string, value string) ([]Message, error)
+	GetMemoriesSession(ctx context.Context, userid string, sessionID string) ([]Message, error)
 }
 
 // IMemorizer is the base interface for working with Redis.
@@ -21,8 +45,36 @@ type IMemorizer interface {
 	Set(ctx context.Context, key string, value any, expiration ...time.Duration) error
 
 	// Get returns the value of the given key.
-	// If the key does not exist, redis.Nil is returned.
+	// If the key does not exist, an error matching ErrNotFound is returned (ErrExpired if the backend
+	// knows the key has just expired); a value that cannot be read is reported as ErrCorrupted.
 	Get(ctx context.Context, key string) (string, error)
+
+	// Delete removes the key. Deleting a missing key is not an error.
+	Delete(ctx context.Context, key string) error
+}
+
+// IUpdater is an optional extension of IMemorizer for backends that can
+// read-modify-write a key atomically. MemoryBox uses it when available so that
+// concurrent writers on the same user never lose messages.
+type IUpdater interface {
+	// Update calls fn with the current value of key (exists is false if the key is
+	// missing or expired) and stores the returned value with an optional expiration.
+	// No other write to key may happen between the read and the write.
+	// If fn returns an error, nothing is stored and the error is returned.
+	Update(ctx context.Context, key string, fn func(old string, exists bool) (string, error), expiration ...time.Duration) error
+}
+
+// IAppender is an optional extension of IMemorizer for backends that store a history as a list
+// of messages and can append to it without rewriting it, e.g. rdb.RedisAdapter with WithNativeLists.
+// MemoryBox uses it for plain appends when the configuration needs no trimming beyond
+// the default KeepSystemPolicy: no TrimPolicy, MaxTokens or Summarizer and the TTLSlidingWrite mode.
+type IAppender interface {
+	// Append atomically adds items, each a JSON encoded Message, to the end of the list stored under key.
+	// If the list then holds more than maxLen items (zero means no limit), it keeps the leading
+	// system messages and the newest items, like KeepSystemPolicy. It sets the TTL to expiration
+	// and returns the whole list. ok is false, and nothing is written, if key holds a value
+	// that is not a list, e.g. a branched conversation; MemoryBox then falls back to a full update.
+	Append(ctx context.Context, key string, items []string, maxLen int, expiration time.Duration) (list []string, ok bool, err error)
 }
 
 type MemoryBox struct {
@@ -52,45 +104,216 @@ func NewMemoryBoxDefault() IMemoryBox {
 
 // AddRaw retrieves the existing messages for a user, appends a new message with the specified role and content,
 // and saves the updated list back to the memory store.
+// If the underlying IMemorizer implements IUpdater, the whole operation is atomic.
 func (b *MemoryBox) AddRaw(ctx context.Context, userid string, role Role, value string) ([]Message, error) {
-	// Initialize the array
-	lastMessages, _ := b.Get(ctx, userid)
+	return b.push(ctx, userid, b.newMessage(role, value))
+}
 
-	data := []Message{}
+// AddMessage appends a fully specified message, e.g. one with a Name or Metadata, to the user's history.
+// ID and CreatedAt are filled in if they are empty.
+func (b *MemoryBox) AddMessage(ctx context.Context, userid string, msg Message) ([]Message, error) {
+	return b.push(ctx, userid, msg)
+}
 
-	// Parse existing messages if any
-	if lastMessages != "" {
-		if err := json.Unmarshal([]byte(lastMessages), &data); err != nil {
-			return data, err
+// push adds msgs to the history stored under key. When the store implements IAppender and the
+// configuration allows it, the messages are appended without rewriting the history; otherwise push uses write.
+func (b *MemoryBox) push(ctx context.Context, key string, msgs ...Message) ([]Message, error) {
+	a, ok := b.IMemorizer.(IAppender)
+	if !ok || b.TrimPolicy != nil || b.MaxTokens > 0 || b.Summarizer != nil || b.TTLMode != TTLSlidingWrite {
+		return b.write(ctx, key, b.appendMessages(msgs...))
+	}
+
+	items := make([]string, len(msgs))
+	for i, m := range msgs {
+		msgs[i] = b.withID(m)
+		data, err := json.Marshal(msgs[i])
+		if err != nil {
+			return nil, err
 		}
+		items[i] = string(data)
+	}
+
+	list, ok, err := a.Append(ctx, key, items, b.ContextLenSize, b.ttl(ctx, key))
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return b.write(ctx, key, b.appendMessages(msgs...))
+	}
+	c, err := decodeConversation("[" + strings.Join(list, ",") + "]")
+	if err != nil {
+		return nil, err
 	}
+	return c.path(), nil
+}
 
-	if len(data) > b.ContextLenSize {
-		if data[0].Role == "system" {
-			// Удаляем второй элемент, сдвигая срез так, чтобы исключить data[1]
-			data = append(data[:1], data[2:]...)
-		} else {
-			// Если первый элемент не system, просто удаляем первый
-			data = data[1:]
+// appendMessages returns a write function that adds msgs to the history.
+func (b *MemoryBox) appendMessages(msgs ...Message) func([]Message) ([]Message, error) {
+	return func(data []Message) ([]Message, error) {
+		// Add the new messages
+		for _, m := range msgs {
+			data = append(data, b.withID(m))
 		}
+		return data, nil
 	}
+}
 
-	// Add the new message
-	data = append(data, Message{
+// newMessage creates a message with a fresh ID and creation time.
+func (b *MemoryBox) newMessage(role Role, value string) Message {
+	return b.withID(Message{
 		Role:    role,
 		Content: value,
 	})
+}
 
-	// Save the updated messages back
-	jsonMsgArr, err := json.Marshal(data)
+// withID fills in the ID and CreatedAt of m if they are empty.
+func (b *MemoryBox) withID(m Message) Message {
+	if m.CreatedAt.IsZero() {
+		m.CreatedAt = b.clock().Now()
+	}
+	if m.ID == "" {
+		m.ID = newULID(m.CreatedAt)
+	}
+	return m
+}
+
+// write atomically applies fn to the history stored under key and returns the trimmed history.
+// Without a Summarizer the trimmed history is what gets stored. With one, trimmed messages stay
+// in the store until they are folded into the rolling summary, which happens once SummaryBatch
+// of them have piled up; a failed summary is retried on a later write.
+func (b *MemoryBox) write(ctx context.Context, key string, fn func([]Message) ([]Message, error)) ([]Message, error) {
+	var fold []Message
+	data, err := b.update(ctx, key, func(data []Message) ([]Message, error) {
+		data, err := fn(data)
+		if err != nil {
+			return data, err
+		}
+		if b.Summarizer == nil {
+			return b.trim(data), nil
+		}
+
+		pending := unsummarized(data, b.trim(data))
+		if limit := b.summaryBatch() * summaryBacklogFactor; len(pending) > limit {
+			// The Summarizer keeps failing: give up on the oldest messages rather than grow forever
+			slog.Error("memorybox: summary backlog full, dropping messages", "key", key, "dropped", len(pending)-limit)
+			data = without(data, pending[:len(pending)-limit])
+			pending = pending[len(pending)-limit:]
+		}
+		if len(pending) >= b.summaryBatch() {
+			fold = pending
+			if i := summaryIndex(data); i >= 0 {
+				fold = append([]Message{data[i]}, fold...)
+			}
+		}
+		return data, nil
+	})
+	if err != nil || len(fold) == 0 {
+		return b.trim(data), err
+	}
+
+	summarized, err := b.summarize(ctx, key, fold)
 	if err != nil {
-		return data, err
+		// The messages stay stored and are folded on a later write
+		slog.Error("memorybox: summarize failed", "key", key, "err", err)
+		return b.trim(data), nil
+	}
+	return b.trim(summarized), nil
+}
+
+// trim applies the token budget and the trim policy to the history.
+func (b *MemoryBox) trim(data []Message) []Message {
+	var policy TrimPolicy = KeepSystemPolicy{MaxMessages: b.ContextLenSize}
+	if b.TrimPolicy != nil {
+		policy = b.TrimPolicy
+	}
+	return policy.Trim(b.fitTokens(data))
+}
+
+// fitTokens drops the oldest non-system messages until the history fits into MaxTokens.
+// The newest message is always kept, even if it alone exceeds the budget.
+func (b *MemoryBox) fitTokens(data []Message) []Message {
+	if b.MaxTokens <= 0 {
+		return data
+	}
+
+	var tokenizer Tokenizer = HeuristicTokenizer{}
+	if b.Tokenizer != nil {
+		tokenizer = b.Tokenizer
+	}
+
+	total := 0
+	for _, m := range data {
+		total += tokenizer.CountTokens(m.Content) + messageTokenOverhead
 	}
-	if err := b.Set(ctx, userid, string(jsonMsgArr), b.ExpireTime); err != nil {
-		return data, err
+
+	data = append([]Message(nil), data...) // Do not modify the caller's slice
+	for i := 0; total > b.MaxTokens && i < len(data)-1; {
+		if data[i].Role == SystemRole {
+			i++
+			continue
+		}
+		total -= tokenizer.CountTokens(data[i].Content) + messageTokenOverhead
+		data = append(data[:i], data[i+1:]...)
+	}
+	return data
+}
+
+// update loads the messages of the active branch stored under key, passes them to fn and saves the result.
+func (b *MemoryBox) update(ctx context.Context, key string, fn func([]Message) ([]Message, error)) ([]Message, error) {
+	data := []Message{}
+
+	err := b.updateConversation(ctx, key, func(c *conversation) error {
+		var err error
+		if data, err = fn(c.path()); err != nil {
+			return err
+		}
+		c.setPath(data)
+		return nil
+	})
+	return data, err
+}
+
+// updateRaw applies fn to the raw value stored under key, applying the TTL mode of the box.
+// When the IMemorizer implements IUpdater the read and the write happen atomically,
+// otherwise it falls back to a plain Get followed by Set.
+func (b *MemoryBox) updateRaw(ctx context.Context, key string, fn func(old string, exists bool) (string, error)) error {
+	return b.updateRawMode(ctx, key, b.TTLMode, fn)
+}
+
+// updateRawMode is updateRaw with an explicit TTL mode.
+func (b *MemoryBox) updateRawMode(ctx context.Context, key string, mode TTLMode, fn func(old string, exists bool) (string, error)) error {
+	ttl := b.ttl(ctx, key)
+	expiration := ttl
+	e, absolute := b.expirer(mode)
+	absolute = absolute && mode == TTLAbsolute && ttl > 0
+	if absolute {
+		expiration = KeepTTL
+	}
+
+	created := false
+	write := func(old string, exists bool) (string, error) {
+		created = !exists
+		return fn(old, exists)
 	}
 
-	return data, nil
+	var err error
+	if u, ok := b.IMemorizer.(IUpdater); ok {
+		err = u.Update(ctx, key, write, expiration)
+	} else {
+		old, getErr := b.Get(ctx, key)
+		if getErr != nil && !errors.Is(getErr, ErrNotFound) {
+			return getErr
+		}
+		var value string
+		if value, err = write(old, getErr == nil); err == nil {
+			err = b.Set(ctx, key, value, expiration)
+		}
+	}
+	if err != nil || !absolute || !created {
+		return err
+	}
+	// A new conversation in TTLAbsolute mode: its TTL starts now and is kept by later writes
+	return e.Expire(ctx, key, ttl)
 }
 
 // Talk adds a user message to the memory for the specified user.
@@ -124,22 +347,62 @@ func (b *MemoryBox) Remember(ctx context.Context, userid string, value string) (
 }
 
 // GetMemories retrieves all stored messages for the specified user.
+// It returns an error matching ErrNotFound if the user has no history.
+// For branched conversations only the path of the active branch is returned.
+// The returned history is trimmed with the current token budget and trim policy.
 func (b *MemoryBox) GetMemories(ctx context.Context, userid string) ([]Message, error) {
-	lastMessages, err := b.Get(ctx, userid)
+	return b.getMemories(ctx, userid)
+}
+
+// getMemories loads and trims the messages stored under key.
+func (b *MemoryBox) getMemories(ctx context.Context, key string) ([]Message, error) {
+	lastMessages, err := b.read(ctx, key)
 	if err != nil {
 		return []Message{}, err
 	}
-	// Initialize the array
-	data := []Message{}
 
 	// Parse existing messages if any
-	if lastMessages != "" {
-		if err := json.Unmarshal([]byte(lastMessages), &data); err != nil {
-			return data, err
+	c, err := decodeConversation(lastMessages)
+	if err != nil {
+		return []Message{}, err
+	}
+
+	return b.trim(c.path()), nil
+}
+
+// Forget erases everything stored for the user: the plain history and all sessions.
+// Use it for "/reset" commands and user deletion requests.
+func (b *MemoryBox) Forget(ctx context.Context, userid string) error {
+	if checkUser(userid) != nil {
+		// Such a user can not have sessions
+		return b.Delete(ctx, userid)
+	}
+	sessions, err := b.getSessions(ctx, userid)
+	if err != nil {
+		return err
+	}
+	for id := range sessions {
+		if err := b.Delete(ctx, SessionKey(userid, id)); err != nil {
+			return err
 		}
 	}
+	if err := b.Delete(ctx, userid+sessionIndexSuffix); err != nil {
+		return err
+	}
+	return b.Delete(ctx, userid)
+}
 
-	return data, nil
+// DeleteMessage removes a single message from the user's history and returns the remaining messages.
+// It returns ErrMessageNotFound if there is no message with the given ID.
+func (b *MemoryBox) DeleteMessage(ctx context.Context, userid string, messageID string) ([]Message, error) {
+	return b.update(ctx, userid, func(data []Message) ([]Message, error) {
+		for i, m := range data {
+			if m.ID == messageID {
+				return append(data[:i], data[i+1:]...), nil
+			}
+		}
+		return data, ErrMessageNotFound
+	})
 }
 
 // ConvertMessagesForReplicate converts a slice of Message structs into a slice of maps with keys "role" and "content",
@@ -0,0 +1,127 @@
+package memorybox_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+func TestAddRawConcurrent(t *testing.T) {
+	const writers, messages = 10, 10
+
+	for _, tt := range []struct {
+		name  string
+		store memorybox.IMemorizer
+	}{
+		{"cache", memorybox.NewCache()},
+		{"sharded", memorybox.NewShardedCache(4)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			box := memorybox.NewMemoryBox(tt.store, memorybox.MemoryBoxConfig{})
+
+			var wg sync.WaitGroup
+			for w := 0; w < writers; w++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					for i := 0; i < messages; i++ {
+						if _, err := box.AddRaw(ctx, "user", memorybox.UserRole, fmt.Sprintf("%d-%d", w, i)); err != nil {
+							t.Error(err)
+							return
+						}
+					}
+				}()
+			}
+			wg.Wait()
+
+			msgs, err := box.GetMemories(ctx, "user")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(msgs) != writers*messages {
+				t.Fatalf("history has %d messages, want %d: concurrent writes were lost", len(msgs), writers*messages)
+			}
+			seen := map[string]bool{}
+			for _, m := range msgs {
+				seen[m.Content] = true
+			}
+			if len(seen) != writers*messages {
+				t.Fatalf("history has %d distinct messages, want %d", len(seen), writers*messages)
+			}
+		})
+	}
+}
+
+func TestForget(t *testing.T) {
+	ctx := context.Background()
+	cache := memorybox.NewCache()
+	box := memorybox.NewMemoryBox(cache, memorybox.MemoryBoxConfig{})
+
+	box.Tell(ctx, "bob", "hi")
+	bobKeys := cache.Stats().Entries
+
+	box.Tell(ctx, "alice", "plain")
+	for _, title := range []string{"trip", "work"} {
+		s, err := box.CreateSession(ctx, "alice", title)
+		if err != nil {
+			t.Fatal(err)
+		}
+		box.TellSession(ctx, "alice", s.ID, title)
+	}
+
+	if err := box.Forget(ctx, "alice"); err != nil {
+		t.Fatal(err)
+	}
+	// The plain history, both session histories and the session index are gone
+	if entries := cache.Stats().Entries; entries != bobKeys {
+		t.Fatalf("cache holds %d keys after Forget, want %d of bob", entries, bobKeys)
+	}
+	if _, err := box.GetMemories(ctx, "alice"); !errors.Is(err, memorybox.ErrNotFound) {
+		t.Fatalf("GetMemories(alice) error = %v, want ErrNotFound", err)
+	}
+	if sessions, err := box.ListSessions(ctx, "alice"); err != nil || len(sessions) != 0 {
+		t.Fatalf("ListSessions(alice) = %v, %v; want none", sessions, err)
+	}
+	if msgs, _ := box.GetMemories(ctx, "bob"); history(msgs) != "hi" {
+		t.Fatalf("bob's history = %s, want hi", history(msgs))
+	}
+
+	// Forgetting a user without any data is not an error
+	if err := box.Forget(ctx, "nobody"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDeleteMessage(t *testing.T) {
+	ctx := context.Background()
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{})
+
+	box.Tell(ctx, "user", "q1")
+	box.Remember(ctx, "user", "a1")
+	msgs, _ := box.Tell(ctx, "user", "q2")
+
+	msgs, err := box.DeleteMessage(ctx, "user", msgs[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(msgs); got != "q1|q2" {
+		t.Fatalf("history after DeleteMessage = %s, want q1|q2", got)
+	}
+	stored, _ := box.GetMemories(ctx, "user")
+	if got := history(stored); got != "q1|q2" {
+		t.Fatalf("stored history = %s, want q1|q2", got)
+	}
+
+	if _, err := box.DeleteMessage(ctx, "user", "unknown"); !errors.Is(err, memorybox.ErrMessageNotFound) {
+		t.Fatalf("DeleteMessage(unknown) error = %v, want ErrMessageNotFound", err)
+	}
+	stored, _ = box.GetMemories(ctx, "user")
+	if got := history(stored); got != "q1|q2" {
+		t.Fatalf("history after a failed DeleteMessage = %s, want q1|q2", got)
+	}
+}
@@ -0,0 +1,280 @@
+package memorybox
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// FsyncPolicy controls how often a persistent MemoryCache forces its write log to disk.
+type FsyncPolicy int
+
+const (
+	// FsyncEverySecond syncs the log once per second: at most one second of writes
+	// can be lost if the machine crashes. It is the default.
+	FsyncEverySecond FsyncPolicy = iota
+	// FsyncAlways syncs the log after every write. It is the safest and the slowest.
+	FsyncAlways
+	// FsyncNever leaves flushing to the operating system. A process crash loses nothing,
+	// a machine crash may lose recent writes.
+	FsyncNever
+)
+
+const (
+	snapshotFile = "snapshot.log" // Full dump of the cache, rewritten on every snapshot.
+	walFile      = "wal.log"      // Writes since the last snapshot, one JSON record per line.
+
+	defaultSnapshotInterval = 5 * time.Minute
+)
+
+// logRecord is one line of the snapshot or the write log.
+type logRecord struct {
+	Op     string    `json:"op"` // "set" or "del"
+	Key    string    `json:"key"`
+	Value  string    `json:"value,omitempty"`
+	Expire time.Time `json:"expire,omitzero"`
+}
+
+// WithFsync sets the fsync policy of a cache created by OpenCache.
+func WithFsync(policy FsyncPolicy) CacheOption {
+	return func(c *MemoryCache) {
+		c.fsync = policy
+	}
+}
+
+// WithSnapshotInterval sets how often a cache created by OpenCache writes a snapshot
+// and truncates its write log. The default is five minutes.
+func WithSnapshotInterval(interval time.Duration) CacheOption {
+	return func(c *MemoryCache) {
+		c.snapshotInterval = interval
+	}
+}
+
+// OpenCache creates a MemoryCache that persists its content in dir, so conversations survive restarts.
+// Every write is appended to a write log; a snapshot of the whole cache is written periodically
+// and the log is truncated. On startup the snapshot and the log are replayed and expired entries skipped.
+// A record cut short by a crash at the end of a file is ignored.
+// Call Close to write a final snapshot and release the files; the cache must not be written after Close.
+func OpenCache(dir string, opts ...CacheOption) (*MemoryCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	c := NewCache(opts...)
+	if err := c.load(dir); err != nil {
+		c.Close() // Stop the janitor started by NewCache
+		return nil, err
+	}
+
+	syncTicker := c.clock.NewTicker(time.Second)
+	snapshotTicker := c.clock.NewTicker(c.snapshotInterval)
+	c.background.Add(1)
+	go c.persister(syncTicker, snapshotTicker)
+	return c, nil
+}
+
+// load replays the snapshot and the write log in dir and opens the log for appending.
+func (c *MemoryCache) load(dir string) error {
+	c.mu.Lock()
+	defer c.unlock()
+
+	c.dir = dir
+	if c.snapshotInterval <= 0 {
+		c.snapshotInterval = defaultSnapshotInterval
+	}
+	if _, err := c.replay(filepath.Join(dir, snapshotFile)); err != nil {
+		return err
+	}
+	valid, err := c.replay(filepath.Join(dir, walFile))
+	if err != nil {
+		return err
+	}
+
+	wal, err := os.OpenFile(filepath.Join(dir, walFile), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	// Cut off a record torn by a crash, so that new records start on a fresh line.
+	if err := wal.Truncate(valid); err != nil {
+		wal.Close()
+		return err
+	}
+	c.wal = wal
+	return nil
+}
+
+// replay loads the records of a snapshot or write log file and returns the size
+// of its valid part, without a last line cut short by a crash. The caller must hold the write lock.
+func (c *MemoryCache) replay(path string) (int64, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	now := c.now()
+	r := bufio.NewReader(f)
+	var valid int64
+	for line := 1; ; line++ {
+		data, err := r.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			// A last line without a newline was cut short by a crash
+			return valid, nil
+		}
+		if err != nil {
+			return valid, err
+		}
+
+		var rec logRecord
+		if err := json.Unmarshal(data, &rec); err != nil {
+			return valid, fmt.Errorf("replay %s:%d: %w: %w", path, line, ErrCorrupted, err)
+		}
+		valid += int64(len(data))
+
+		switch rec.Op {
+		case "set":
+			if !rec.Expire.IsZero() && now.After(rec.Expire) {
+				c.drop(rec.Key)
+				continue
+			}
+			c.store(rec.Key, rec.Value, rec.Expire)
+		case "del":
+			c.drop(rec.Key)
+		}
+	}
+}
+
+// persist appends a record to the write log. It is a no-op for caches without persistence.
+// The caller must hold the write lock.
+func (c *MemoryCache) persist(rec logRecord) error {
+	if c.wal == nil {
+		return nil
+	}
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+	if _, err := c.wal.Write(append(data, '\n')); err != nil {
+		return err
+	}
+	if c.fsync == FsyncAlways {
+		return c.wal.Sync()
+	}
+	c.dirty.Store(true)
+	return nil
+}
+
+// persister syncs the write log and writes snapshots in the background until Close.
+func (c *MemoryCache) persister(syncTicker, snapshotTicker Ticker) {
+	defer c.background.Done()
+	defer syncTicker.Stop()
+	defer snapshotTicker.Stop()
+
+	for {
+		select {
+		case <-syncTicker.C():
+			if c.fsync == FsyncEverySecond && c.dirty.Swap(false) {
+				if err := c.wal.Sync(); err != nil {
+					slog.Error("memorybox: fsync write log", "err", err)
+				}
+			}
+		case <-snapshotTicker.C():
+			if err := c.Snapshot(); err != nil {
+				slog.Error("memorybox: snapshot", "err", err)
+			}
+		case <-c.stop:
+			return
+		}
+	}
+}
+
+// Snapshot writes the whole cache to disk and truncates the write log.
+// The snapshot is written to a temporary file, synced and renamed, so a crash
+// leaves either the old or the new snapshot in place. It is a no-op without persistence.
+func (c *MemoryCache) Snapshot() error {
+	c.mu.Lock()
+	defer c.unlock()
+	if c.wal == nil {
+		return nil
+	}
+
+	tmp := filepath.Join(c.dir, snapshotFile+".tmp")
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	now := c.now()
+	for key, mf := range c.memory {
+		if mf.expired(now) {
+			continue
+		}
+		value, _ := mf.Value.(string)
+		if err := enc.Encode(logRecord{Op: "set", Key: key, Value: value, Expire: mf.ExpireTime}); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, filepath.Join(c.dir, snapshotFile)); err != nil {
+		return err
+	}
+	if err := syncDir(c.dir); err != nil {
+		return err
+	}
+
+	// The snapshot now holds everything; replaying the old log on top of it would be harmless,
+	// so a crash before the truncation is safe.
+	if err := c.wal.Truncate(0); err != nil {
+		return err
+	}
+	return c.wal.Sync()
+}
+
+// closePersistence writes a final snapshot and closes the write log.
+func (c *MemoryCache) closePersistence() error {
+	if c.dir == "" {
+		return nil
+	}
+	err := c.Snapshot()
+
+	c.mu.Lock()
+	defer c.unlock()
+	if c.wal != nil {
+		if cerr := c.wal.Close(); err == nil {
+			err = cerr
+		}
+		c.wal = nil
+	}
+	return err
+}
+
+// syncDir flushes directory metadata, making a rename durable.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,141 @@
+package memorybox_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+	"github.com/rmay1er/magic-memory-box-go/memorybox/clocktest"
+)
+
+// writeFile creates a persistence file with the given raw content.
+func writeFile(t *testing.T, dir, name, content string) {
+	t.Helper()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// wantValue fails the test unless key holds want.
+func wantValue(t *testing.T, c memorybox.IMemorizer, key, want string) {
+	t.Helper()
+	got, err := c.Get(context.Background(), key)
+	if err != nil || got != want {
+		t.Fatalf("Get(%q) = %q, %v; want %q", key, got, err, want)
+	}
+}
+
+// wantMissing fails the test unless key is missing.
+func wantMissing(t *testing.T, c memorybox.IMemorizer, key string) {
+	t.Helper()
+	if got, err := c.Get(context.Background(), key); !errors.Is(err, memorybox.ErrNotFound) {
+		t.Fatalf("Get(%q) = %q, %v; want ErrNotFound", key, got, err)
+	}
+}
+
+func TestOpenCacheTornWriteLog(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "wal.log", `{"op":"set","key":"a","value":"1"}`+"\n"+`{"op":"set","key":"b","val`)
+
+	c, err := memorybox.OpenCache(dir, memorybox.WithFsync(memorybox.FsyncAlways))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantValue(t, c, "a", "1")
+	wantMissing(t, c, "b")
+
+	// The torn record is cut off, so the next record starts on its own line.
+	if err := c.Set(context.Background(), "c", "3"); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `{"op":"set","key":"a","value":"1"}` + "\n" + `{"op":"set","key":"c","value":"3"}` + "\n"
+	if string(data) != want {
+		t.Fatalf("write log = %q, want %q", data, want)
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err = memorybox.OpenCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	wantValue(t, c, "a", "1")
+	wantValue(t, c, "c", "3")
+}
+
+func TestOpenCacheCrashBeforeLogTruncate(t *testing.T) {
+	// A crash after the snapshot rename but before the truncation leaves the whole log
+	// next to a snapshot that already contains it; replaying both must give the same state.
+	dir := t.TempDir()
+	writeFile(t, dir, "snapshot.log", `{"op":"set","key":"a","value":"2"}`+"\n")
+	writeFile(t, dir, "wal.log", `{"op":"set","key":"a","value":"1"}`+"\n"+
+		`{"op":"set","key":"b","value":"1"}`+"\n"+
+		`{"op":"set","key":"a","value":"2"}`+"\n"+
+		`{"op":"del","key":"b"}`+"\n")
+
+	c, err := memorybox.OpenCache(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	wantValue(t, c, "a", "2")
+	wantMissing(t, c, "b")
+}
+
+func TestOpenCacheSkipsExpired(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	clock := clocktest.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+
+	c, err := memorybox.OpenCache(dir, memorybox.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set(ctx, "short", "1", time.Minute)
+	c.Set(ctx, "long", "2", time.Hour)
+	c.Set(ctx, "forever", "3")
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The log also holds an entry that expired before the snapshot was taken.
+	writeFile(t, dir, "wal.log", `{"op":"set","key":"old","value":"4","expire":"2024-12-31T00:00:00Z"}`+"\n")
+
+	clock.Advance(2 * time.Minute)
+	c, err = memorybox.OpenCache(dir, memorybox.WithClock(clock))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	wantMissing(t, c, "short")
+	wantMissing(t, c, "old")
+	wantValue(t, c, "long", "2")
+	wantValue(t, c, "forever", "3")
+}
+
+func TestOpenCacheCorrupted(t *testing.T) {
+	dir := t.TempDir()
+	writeFile(t, dir, "wal.log", "not json\n"+`{"op":"set","key":"a","value":"1"}`+"\n")
+
+	// The janitor started for the cache must be stopped when OpenCache fails.
+	_, err := memorybox.OpenCache(dir, memorybox.WithJanitor(time.Millisecond))
+	if !errors.Is(err, memorybox.ErrCorrupted) {
+		t.Fatalf("OpenCache error = %v, want ErrCorrupted", err)
+	}
+
+	// The damaged files are left for inspection.
+	data, err := os.ReadFile(filepath.Join(dir, "wal.log"))
+	if err != nil || len(data) == 0 {
+		t.Fatalf("write log was modified: %q, %v", data, err)
+	}
+}
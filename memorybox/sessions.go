@@ -0,0 +1,205 @@
+package memorybox
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// keySeparator separates the user ID from the rest of the session keys. It is the ASCII unit separator,
+// which does not occur in real user IDs, so session keys never collide with the key of a plain history:
+// the plain history of user "alice:sessions" is not the session index of "alice".
+const keySeparator = "\x1f"
+
+// SessionSeparator joins a user ID and a session ID into the storage key of a session history.
+const SessionSeparator = keySeparator + "session:"
+
+// sessionIndexSuffix is appended to a user ID to get the key of the user's session index.
+const sessionIndexSuffix = keySeparator + "sessions"
+
+// checkUser returns ErrInvalidUserID if userid contains keySeparator and so can not own sessions.
+func checkUser(userid string) error {
+	if strings.Contains(userid, keySeparator) {
+		return fmt.Errorf("%w: %q contains the key separator", ErrInvalidUserID, userid)
+	}
+	return nil
+}
+
+// SessionKey returns the storage key of the history of a user's session.
+// userid must not contain the ASCII unit separator "\x1f", see ErrInvalidUserID.
+func SessionKey(userid string, sessionID string) string {
+	return userid + SessionSeparator + sessionID
+}
+
+// ParseSessionKey splits a storage key created by SessionKey into the user ID and the session ID.
+// ok is false for keys of plain, session-less histories.
+func ParseSessionKey(key string) (userid string, sessionID string, ok bool) {
+	return strings.Cut(key, SessionSeparator)
+}
+
+// KeyUser returns the user ID a storage key belongs to: the key of the plain history,
+// of a session history or of the session index. Backends use it to keep a user's keys together,
+// e.g. on one Redis Cluster slot.
+func KeyUser(key string) string {
+	if userid, _, ok := ParseSessionKey(key); ok {
+		return userid
+	}
+	return strings.TrimSuffix(key, sessionIndexSuffix)
+}
+
+// CreateSession starts a new conversation for the user and returns its metadata.
+func (b *MemoryBox) CreateSession(ctx context.Context, userid string, title string) (Session, error) {
+	now := b.clock().Now()
+	session := Session{
+		ID:         NewULID(),
+		Title:      title,
+		CreatedAt:  now,
+		LastActive: now,
+	}
+	err := b.updateSessions(ctx, userid, func(sessions map[string]Session) error {
+		sessions[session.ID] = session
+		return nil
+	})
+	return session, err
+}
+
+// ListSessions returns the user's sessions, most recently active first.
+// Sessions whose history has already expired are not listed.
+func (b *MemoryBox) ListSessions(ctx context.Context, userid string) ([]Session, error) {
+	sessions, err := b.getSessions(ctx, userid)
+	if err != nil {
+		return nil, err
+	}
+
+	now := b.clock().Now()
+	ttl := b.ttl(ctx, userid)
+	out := make([]Session, 0, len(sessions))
+	for _, s := range sessions {
+		// Reads do not update LastActive, so in TTLSlidingReadWrite mode the index cannot tell
+		// whether a history is still alive and every session is listed.
+		expires := s.LastActive.Add(ttl)
+		if b.TTLMode == TTLAbsolute {
+			expires = s.CreatedAt.Add(ttl)
+		}
+		if ttl > 0 && b.TTLMode != TTLSlidingReadWrite && now.After(expires) {
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].LastActive.After(out[j].LastActive)
+	})
+	return out, nil
+}
+
+// DeleteSession removes the session and its history.
+func (b *MemoryBox) DeleteSession(ctx context.Context, userid string, sessionID string) error {
+	err := b.updateSessions(ctx, userid, func(sessions map[string]Session) error {
+		if _, ok := sessions[sessionID]; !ok {
+			return ErrSessionNotFound
+		}
+		delete(sessions, sessionID)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return b.Delete(ctx, SessionKey(userid, sessionID))
+}
+
+// AddRawSession appends a message with the specified role to the history of a session
+// and updates the session's last activity time.
+func (b *MemoryBox) AddRawSession(ctx context.Context, userid string, sessionID string, role Role, value string) ([]Message, error) {
+	if err := b.touchSession(ctx, userid, sessionID); err != nil {
+		return nil, err
+	}
+	return b.push(ctx, SessionKey(userid, sessionID), b.newMessage(role, value))
+}
+
+// AddMessageSession appends a fully specified message to the history of a session.
+// ID and CreatedAt are filled in if they are empty.
+func (b *MemoryBox) AddMessageSession(ctx context.Context, userid string, sessionID string, msg Message) ([]Message, error) {
+	if err := b.touchSession(ctx, userid, sessionID); err != nil {
+		return nil, err
+	}
+	return b.push(ctx, SessionKey(userid, sessionID), msg)
+}
+
+// TellSession adds a user message to the history of a session.
+func (b *MemoryBox) TellSession(ctx context.Context, userid string, sessionID string, value string) ([]Message, error) {
+	return b.AddRawSession(ctx, userid, sessionID, UserRole, value)
+}
+
+// RememberSession adds an assistant message to the history of a session.
+func (b *MemoryBox) RememberSession(ctx context.Context, userid string, sessionID string, value string) ([]Message, error) {
+	return b.AddRawSession(ctx, userid, sessionID, AssistantRole, value)
+}
+
+// GetMemoriesSession retrieves all stored messages of a session.
+func (b *MemoryBox) GetMemoriesSession(ctx context.Context, userid string, sessionID string) ([]Message, error) {
+	if err := checkUser(userid); err != nil {
+		return []Message{}, err
+	}
+	return b.getMemories(ctx, SessionKey(userid, sessionID))
+}
+
+// touchSession updates the last activity time of a session.
+// It returns ErrSessionNotFound if the session was never created or was deleted.
+func (b *MemoryBox) touchSession(ctx context.Context, userid string, sessionID string) error {
+	return b.updateSessions(ctx, userid, func(sessions map[string]Session) error {
+		s, ok := sessions[sessionID]
+		if !ok {
+			return ErrSessionNotFound
+		}
+		s.LastActive = b.clock().Now()
+		sessions[sessionID] = s
+		return nil
+	})
+}
+
+// getSessions loads the session index of the user.
+func (b *MemoryBox) getSessions(ctx context.Context, userid string) (map[string]Session, error) {
+	if err := checkUser(userid); err != nil {
+		return nil, err
+	}
+	sessions := map[string]Session{}
+	raw, err := b.Get(ctx, userid+sessionIndexSuffix)
+	if errors.Is(err, ErrNotFound) || (err == nil && raw == "") {
+		return sessions, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal([]byte(raw), &sessions); err != nil {
+		return nil, fmt.Errorf("%w: session index: %w", ErrCorrupted, err)
+	}
+	return sessions, nil
+}
+
+// updateSessions atomically applies fn to the session index of the user.
+// The index expires together with the most recently active session, so its TTL always slides on write.
+func (b *MemoryBox) updateSessions(ctx context.Context, userid string, fn func(map[string]Session) error) error {
+	if err := checkUser(userid); err != nil {
+		return err
+	}
+	return b.updateRawMode(ctx, userid+sessionIndexSuffix, TTLSlidingWrite, func(old string, exists bool) (string, error) {
+		sessions := map[string]Session{}
+		if exists && old != "" {
+			if err := json.Unmarshal([]byte(old), &sessions); err != nil {
+				return "", fmt.Errorf("%w: session index: %w", ErrCorrupted, err)
+			}
+		}
+		if err := fn(sessions); err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(sessions)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	})
+}
@@ -0,0 +1,65 @@
+package memorybox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+func TestSessionKeysDoNotCollideWithPlainHistories(t *testing.T) {
+	ctx := context.Background()
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{})
+
+	session, err := box.CreateSession(ctx, "alice", "Trip planning")
+	if err != nil {
+		t.Fatal(err)
+	}
+	box.TellSession(ctx, "alice", session.ID, "where to go?")
+
+	// User IDs that look like alice's session keys are ordinary users of the plain API
+	for _, userid := range []string{"alice:sessions", "alice:session:" + session.ID, "bot:session-7"} {
+		if _, err := box.Tell(ctx, userid, "hello"); err != nil {
+			t.Fatalf("Tell(%q) error = %v", userid, err)
+		}
+		if err := box.Forget(ctx, userid); err != nil {
+			t.Fatalf("Forget(%q) error = %v", userid, err)
+		}
+	}
+
+	sessions, err := box.ListSessions(ctx, "alice")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sessions) != 1 || sessions[0].ID != session.ID {
+		t.Fatalf("sessions = %+v, want only %s", sessions, session.ID)
+	}
+	msgs, err := box.GetMemoriesSession(ctx, "alice", session.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(msgs); got != "where to go?" {
+		t.Fatalf("session history = %s, want where to go?", got)
+	}
+}
+
+func TestSessionsRejectKeySeparator(t *testing.T) {
+	ctx := context.Background()
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{})
+	userid := "alice\x1fsessions"
+
+	if _, err := box.CreateSession(ctx, userid, "Trip planning"); !errors.Is(err, memorybox.ErrInvalidUserID) {
+		t.Fatalf("CreateSession error = %v, want ErrInvalidUserID", err)
+	}
+	if _, err := box.ListSessions(ctx, userid); !errors.Is(err, memorybox.ErrInvalidUserID) {
+		t.Fatalf("ListSessions error = %v, want ErrInvalidUserID", err)
+	}
+	// The plain history has no such restriction
+	if _, err := box.Tell(ctx, userid, "hello"); err != nil {
+		t.Fatal(err)
+	}
+	if err := box.Forget(ctx, userid); err != nil {
+		t.Fatal(err)
+	}
+}
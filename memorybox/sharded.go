@@ -0,0 +1,95 @@
+package memorybox
+
+import (
+	"context"
+	"hash/fnv"
+	"time"
+)
+
+// NewShardedCache creates a cache that spreads keys over n independently locked MemoryCache shards,
+// so that concurrent conversations do not contend on a single mutex.
+// It implements the same IMemorizer, IUpdater and IExpirer contract as MemoryCache.
+// The options apply to every shard; WithMaxEntries and WithMaxBytes limits are split evenly between shards.
+func NewShardedCache(n int, opts ...CacheOption) *ShardedCache {
+	if n < 1 {
+		n = 1
+	}
+
+	// Read the limits to divide them between the shards.
+	tmpl := &MemoryCache{}
+	for _, opt := range opts {
+		opt(tmpl)
+	}
+	shardOpts := append([]CacheOption{}, opts...)
+	if tmpl.maxEntries > 0 {
+		shardOpts = append(shardOpts, WithMaxEntries((tmpl.maxEntries+n-1)/n))
+	}
+	if tmpl.maxBytes > 0 {
+		shardOpts = append(shardOpts, WithMaxBytes((tmpl.maxBytes+int64(n)-1)/int64(n)))
+	}
+
+	c := &ShardedCache{shards: make([]*MemoryCache, n)}
+	for i := range c.shards {
+		c.shards[i] = NewCache(shardOpts...)
+	}
+	return c
+}
+
+// shard returns the shard responsible for key.
+func (c *ShardedCache) shard(key string) *MemoryCache {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return c.shards[h.Sum32()%uint32(len(c.shards))]
+}
+
+// Set stores a value in the shard that owns key. See MemoryCache.Set.
+func (c *ShardedCache) Set(ctx context.Context, key string, value any, expiration ...time.Duration) error {
+	return c.shard(key).Set(ctx, key, value, expiration...)
+}
+
+// Get retrieves a value from the shard that owns key. See MemoryCache.Get.
+func (c *ShardedCache) Get(ctx context.Context, key string) (string, error) {
+	return c.shard(key).Get(ctx, key)
+}
+
+// Update atomically updates key in the shard that owns it. See MemoryCache.Update.
+// Only that shard is locked, so updates of keys in other shards run in parallel.
+func (c *ShardedCache) Update(ctx context.Context, key string, fn func(old string, exists bool) (string, error), expiration ...time.Duration) error {
+	return c.shard(key).Update(ctx, key, fn, expiration...)
+}
+
+// Expire sets the TTL of key in the shard that owns it. See MemoryCache.Expire.
+func (c *ShardedCache) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return c.shard(key).Expire(ctx, key, expiration)
+}
+
+// GetEx reads key and sets its TTL in the shard that owns it. See MemoryCache.GetEx.
+func (c *ShardedCache) GetEx(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	return c.shard(key).GetEx(ctx, key, expiration)
+}
+
+// Delete removes key from the shard that owns it. See MemoryCache.Delete.
+func (c *ShardedCache) Delete(ctx context.Context, key string) error {
+	return c.shard(key).Delete(ctx, key)
+}
+
+// Stats returns the counters summed over all shards.
+func (c *ShardedCache) Stats() CacheStats {
+	var total CacheStats
+	for _, s := range c.shards {
+		st := s.Stats()
+		total.Entries += st.Entries
+		total.Bytes += st.Bytes
+		total.Evictions += st.Evictions
+		total.Expirations += st.Expirations
+	}
+	return total
+}
+
+// Close stops the janitors of all shards.
+func (c *ShardedCache) Close() error {
+	for _, s := range c.shards {
+		s.Close()
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package memorybox_test
+
+import (
+	"context"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// benchmarkParallelTurns runs Tell/Remember turns from parallel workers. Every worker talks
+// to its own block of 1000 users, like a bot handling many chats at once.
+func benchmarkParallelTurns(b *testing.B, cache memorybox.IMemorizer) {
+	box := memorybox.NewMemoryBox(cache, memorybox.MemoryBoxConfig{
+		ContextLenSize: 20,
+		ExpireTime:     time.Hour,
+	})
+	ctx := context.Background()
+	var users atomic.Int64
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		base := users.Add(1000)
+		for i := int64(0); pb.Next(); i++ {
+			userid := "user-" + strconv.FormatInt(base+i%1000, 10)
+			if _, err := box.Tell(ctx, userid, "Hello! How are you?"); err != nil {
+				b.Error(err)
+				return
+			}
+			if _, err := box.Remember(ctx, userid, "I'm doing great, thank you!"); err != nil {
+				b.Error(err)
+				return
+			}
+		}
+	})
+}
+
+func BenchmarkMemoryCacheParallel(b *testing.B) {
+	benchmarkParallelTurns(b, memorybox.NewCache())
+}
+
+func BenchmarkShardedCacheParallel(b *testing.B) {
+	benchmarkParallelTurns(b, memorybox.NewShardedCache(64))
+}
@@ -0,0 +1,141 @@
+package memorybox
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"time"
+)
+
+// StreamStatusKey is the Metadata key that holds the state of a streamed assistant message.
+const StreamStatusKey = "stream_status"
+
+// Values stored under StreamStatusKey.
+const (
+	StreamStreaming   = "streaming"   // The reply is still being received; Content holds the last checkpoint
+	StreamComplete    = "complete"    // The reply was received in full
+	StreamInterrupted = "interrupted" // The stream stopped early; Content holds everything received
+)
+
+// defaultStreamCheckpoint is how often a partial reply is saved when StreamCheckpoint is not set.
+const defaultStreamCheckpoint = time.Second
+
+// RememberStream reads an assistant reply from r and stores it while it arrives.
+// See RememberChunks for checkpointing and cancellation behavior.
+func (b *MemoryBox) RememberStream(ctx context.Context, userid string, r io.Reader) ([]Message, error) {
+	chunks := make(chan string)
+	var readErr error
+	go func() {
+		defer close(chunks)
+		buf := make([]byte, 4096)
+		for {
+			n, err := r.Read(buf)
+			if n > 0 {
+				select {
+				case chunks <- string(buf[:n]):
+				case <-ctx.Done():
+					readErr = ctx.Err()
+					return
+				}
+			}
+			if err != nil {
+				if !errors.Is(err, io.EOF) {
+					readErr = err
+				}
+				return
+			}
+		}
+	}()
+
+	msgs, err := b.rememberChunks(ctx, userid, chunks, func() error { return readErr })
+	if err == nil {
+		err = readErr
+	}
+	return msgs, err
+}
+
+// RememberChunks stores an assistant reply that arrives as a stream of text chunks.
+// The message is added right away with the stream status "streaming" and its content is
+// checkpointed every StreamCheckpoint, so a crash loses at most one interval of text.
+// When chunks is closed the message is finalized as "complete". If ctx is cancelled first,
+// the text received so far is saved with the status "interrupted" and ctx.Err() is returned.
+func (b *MemoryBox) RememberChunks(ctx context.Context, userid string, chunks <-chan string) ([]Message, error) {
+	return b.rememberChunks(ctx, userid, chunks, func() error { return nil })
+}
+
+// rememberChunks implements RememberChunks. failed is checked after chunks is closed;
+// a non-nil error marks the message as interrupted.
+func (b *MemoryBox) rememberChunks(ctx context.Context, userid string, chunks <-chan string, failed func() error) ([]Message, error) {
+	msg := b.newMessage(AssistantRole, "")
+	msg.Metadata = map[string]any{StreamStatusKey: StreamStreaming}
+	data, err := b.write(ctx, userid, b.appendMessages(msg))
+	if err != nil {
+		return data, err
+	}
+
+	interval := b.StreamCheckpoint
+	if interval <= 0 {
+		interval = defaultStreamCheckpoint
+	}
+	ticker := b.clock().NewTicker(interval)
+	defer ticker.Stop()
+
+	var content strings.Builder
+	saved := 0
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				// A producer that stopped because ctx was cancelled closes chunks too,
+				// so this branch can win the race against ctx.Done()
+				if ctx.Err() != nil {
+					return b.interruptStream(ctx, userid, msg.ID, content.String())
+				}
+				status := StreamComplete
+				if failed() != nil {
+					status = StreamInterrupted
+				}
+				return b.saveStream(ctx, userid, msg.ID, content.String(), status)
+			}
+			content.WriteString(chunk)
+		case <-ticker.C():
+			if content.Len() == saved {
+				continue
+			}
+			if _, err := b.saveStream(ctx, userid, msg.ID, content.String(), StreamStreaming); err != nil {
+				return nil, err
+			}
+			saved = content.Len()
+		case <-ctx.Done():
+			return b.interruptStream(ctx, userid, msg.ID, content.String())
+		}
+	}
+}
+
+// interruptStream saves the partial reply of a cancelled stream and returns ctx.Err().
+// The save uses a context without cancellation, because the caller's context is gone.
+func (b *MemoryBox) interruptStream(ctx context.Context, userid string, messageID string, content string) ([]Message, error) {
+	data, err := b.saveStream(context.WithoutCancel(ctx), userid, messageID, content, StreamInterrupted)
+	if err != nil {
+		return data, err
+	}
+	return data, ctx.Err()
+}
+
+// saveStream updates the content and the stream status of the streamed message.
+func (b *MemoryBox) saveStream(ctx context.Context, userid string, messageID string, content string, status string) ([]Message, error) {
+	return b.update(ctx, userid, func(data []Message) ([]Message, error) {
+		for i, m := range data {
+			if m.ID == messageID {
+				data[i].Content = content
+				if data[i].Metadata == nil {
+					data[i].Metadata = map[string]any{}
+				}
+				data[i].Metadata[StreamStatusKey] = status
+				return data, nil
+			}
+		}
+		return data, ErrMessageNotFound
+	})
+}
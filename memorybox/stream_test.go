@@ -0,0 +1,72 @@
+package memorybox_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+func TestRememberStreamCancelledIsInterrupted(t *testing.T) {
+	// The reader goroutine closes its chunk channel when ctx is cancelled, so the consumer
+	// sees ctx.Done() and the closed channel at the same time; repeat to hit both orders.
+	for i := 0; i < 100; i++ {
+		ctx, cancel := context.WithCancel(context.Background())
+		store := &cancellingStore{MemoryCache: memorybox.NewCache(), cancel: cancel}
+		box := memorybox.NewMemoryBox(store, memorybox.MemoryBoxConfig{})
+
+		_, err := box.RememberStream(ctx, "user", strings.NewReader("partial reply"))
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("iteration %d: RememberStream error = %v, want context.Canceled", i, err)
+		}
+
+		msgs, err := box.GetMemories(context.Background(), "user")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(msgs) != 1 {
+			t.Fatalf("got %d messages, want 1", len(msgs))
+		}
+		if status := msgs[0].Metadata[memorybox.StreamStatusKey]; status != memorybox.StreamInterrupted {
+			t.Fatalf("iteration %d: stream status = %v, want %q", i, status, memorybox.StreamInterrupted)
+		}
+	}
+}
+
+// cancellingStore cancels the stream's context while the first write is in progress,
+// like a client that goes away before the reply has started, and gives the reader goroutine
+// time to notice it and close its chunk channel.
+type cancellingStore struct {
+	*memorybox.MemoryCache
+	cancel context.CancelFunc
+	once   sync.Once
+}
+
+func (s *cancellingStore) Update(ctx context.Context, key string, fn func(old string, exists bool) (string, error), expiration ...time.Duration) error {
+	s.once.Do(func() {
+		s.cancel()
+		time.Sleep(5 * time.Millisecond)
+	})
+	return s.MemoryCache.Update(ctx, key, fn, expiration...)
+}
+
+func TestRememberStreamComplete(t *testing.T) {
+	ctx := context.Background()
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{})
+
+	msgs, err := box.RememberStream(ctx, "user", io.MultiReader(
+		strings.NewReader("Hello, "), strings.NewReader("world"),
+	))
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := msgs[len(msgs)-1]
+	if last.Content != "Hello, world" || last.Metadata[memorybox.StreamStatusKey] != memorybox.StreamComplete {
+		t.Fatalf("got %q (%v), want complete %q", last.Content, last.Metadata[memorybox.StreamStatusKey], "Hello, world")
+	}
+}
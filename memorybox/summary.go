@@ -0,0 +1,101 @@
+package memorybox
+
+import (
+	"context"
+	"strings"
+)
+
+// SummaryPrefix starts the content of the rolling summary message.
+// The summary is stored as a system message, so every converter and model understands it.
+const SummaryPrefix = "Summary of the earlier conversation: "
+
+const (
+	// defaultSummaryBatch is the number of trimmed messages folded at once when SummaryBatch is zero.
+	defaultSummaryBatch = 4
+
+	// summaryBacklogFactor bounds the trimmed messages kept while the Summarizer fails,
+	// as a multiple of the batch size.
+	summaryBacklogFactor = 8
+)
+
+// Summarizer turns messages that are about to be trimmed into a short summary.
+// When a summary already exists, it is passed as the first message, so the result
+// should fold the old summary and the new messages together.
+// A typical implementation asks an LLM to summarize the messages.
+type Summarizer func(ctx context.Context, msgs []Message) (string, error)
+
+// summarize calls the Summarizer outside of any lock and then stores the new summary
+// right after the leading system messages, replacing the previous one, and removes the folded messages.
+// Concurrent summaries of the same history are not merged: the last one wins.
+func (b *MemoryBox) summarize(ctx context.Context, key string, msgs []Message) ([]Message, error) {
+	summary, err := b.Summarizer(ctx, msgs)
+	if err != nil {
+		return nil, err
+	}
+	return b.update(ctx, key, func(data []Message) ([]Message, error) {
+		return withSummary(without(data, msgs), b.newMessage(SystemRole, SummaryPrefix+summary)), nil
+	})
+}
+
+// summaryBatch returns how many trimmed messages are folded into the summary at once.
+func (b *MemoryBox) summaryBatch() int {
+	if b.SummaryBatch > 0 {
+		return b.SummaryBatch
+	}
+	return defaultSummaryBatch
+}
+
+// unsummarized returns the messages of data that the trimmed view leaves out, except the summary itself.
+func unsummarized(data, view []Message) []Message {
+	var out []Message
+	for _, m := range dropped(data, view) {
+		if !isSummary(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// without returns data with the messages in drop removed. The summary message is never removed.
+func without(data, drop []Message) []Message {
+	ids := make(map[string]bool, len(drop))
+	for _, m := range drop {
+		ids[m.ID] = true
+	}
+	out := make([]Message, 0, len(data))
+	for _, m := range data {
+		if !ids[m.ID] || isSummary(m) {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// withSummary replaces the summary message in data with msg or inserts msg after the leading system messages.
+func withSummary(data []Message, msg Message) []Message {
+	if i := summaryIndex(data); i >= 0 {
+		data[i] = msg
+		return data
+	}
+
+	i := leadingSystem(data)
+	data = append(data, Message{})
+	copy(data[i+1:], data[i:])
+	data[i] = msg
+	return data
+}
+
+// summaryIndex returns the index of the summary message in data, or -1 if there is none.
+func summaryIndex(data []Message) int {
+	for i, m := range data {
+		if isSummary(m) {
+			return i
+		}
+	}
+	return -1
+}
+
+// isSummary reports whether m is the rolling summary message.
+func isSummary(m Message) bool {
+	return m.Role == SystemRole && strings.HasPrefix(m.Content, SummaryPrefix)
+}
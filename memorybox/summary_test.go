@@ -0,0 +1,138 @@
+package memorybox_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// fakeSummarizer records its calls and joins the contents of the folded messages.
+type fakeSummarizer struct {
+	calls [][]memorybox.Message
+	err   error
+}
+
+func (f *fakeSummarizer) summarize(ctx context.Context, msgs []memorybox.Message) (string, error) {
+	f.calls = append(f.calls, msgs)
+	if f.err != nil {
+		return "", f.err
+	}
+	var parts []string
+	for _, m := range msgs {
+		parts = append(parts, strings.TrimPrefix(m.Content, memorybox.SummaryPrefix))
+	}
+	return strings.Join(parts, ","), nil
+}
+
+// storedMessages returns the raw history stored for the user.
+func storedMessages(t *testing.T, cache memorybox.IMemorizer, userid string) []memorybox.Message {
+	t.Helper()
+	raw, err := cache.Get(context.Background(), userid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var msgs []memorybox.Message
+	if err := json.Unmarshal([]byte(raw), &msgs); err != nil {
+		t.Fatal(err)
+	}
+	return msgs
+}
+
+func contents(msgs []memorybox.Message) []string {
+	out := make([]string, len(msgs))
+	for i, m := range msgs {
+		out[i] = m.Content
+	}
+	return out
+}
+
+func TestSummarizerFoldsInBatches(t *testing.T) {
+	ctx := context.Background()
+	cache := memorybox.NewCache()
+	fake := &fakeSummarizer{}
+	box := memorybox.NewMemoryBox(cache, memorybox.MemoryBoxConfig{
+		ContextLenSize: 3,
+		Summarizer:     fake.summarize,
+		SummaryBatch:   2,
+	})
+
+	box.AddRaw(ctx, "user", memorybox.SystemRole, "sys")
+	var msgs []memorybox.Message
+	for _, text := range []string{"m1", "m2", "m3", "m4"} {
+		var err error
+		if msgs, err = box.Tell(ctx, "user", text); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// m1 and m2 are trimmed after m3 and m4; only then is the summarizer called, once.
+	if len(fake.calls) != 1 {
+		t.Fatalf("summarizer called %d times, want 1", len(fake.calls))
+	}
+	if got := contents(fake.calls[0]); strings.Join(got, "|") != "m1|m2" {
+		t.Fatalf("folded %q, want [m1 m2]", got)
+	}
+	// The summary counts as a system message, so the limit of 3 leaves room for one more.
+	want := []string{"sys", memorybox.SummaryPrefix + "m1,m2", "m4"}
+	if got := contents(msgs); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("history = %q, want %q", got, want)
+	}
+
+	// m3 is hidden but kept until the next batch, which also includes the previous summary.
+	box.Tell(ctx, "user", "m5")
+	if len(fake.calls) != 2 {
+		t.Fatalf("summarizer called %d times, want 2", len(fake.calls))
+	}
+	if got := contents(fake.calls[1]); strings.Join(got, "|") != memorybox.SummaryPrefix+"m1,m2|m3|m4" {
+		t.Fatalf("folded %q, want the old summary, m3 and m4", got)
+	}
+	stored := storedMessages(t, cache, "user")
+	want = []string{"sys", memorybox.SummaryPrefix + "m1,m2,m3,m4", "m5"}
+	if got := contents(stored); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("stored = %q, want %q", got, want)
+	}
+}
+
+func TestSummarizerFailureKeepsMessages(t *testing.T) {
+	ctx := context.Background()
+	cache := memorybox.NewCache()
+	fake := &fakeSummarizer{err: errors.New("model unavailable")}
+	box := memorybox.NewMemoryBox(cache, memorybox.MemoryBoxConfig{
+		ContextLenSize: 2,
+		Summarizer:     fake.summarize,
+		SummaryBatch:   1,
+	})
+
+	for _, text := range []string{"m1", "m2", "m3"} {
+		if _, err := box.Tell(ctx, "user", text); err != nil {
+			t.Fatalf("Tell must not fail when the summarizer does: %v", err)
+		}
+	}
+	if len(fake.calls) == 0 {
+		t.Fatal("summarizer was not called")
+	}
+
+	// Nothing was lost: the trimmed message is still stored, though hidden from the history.
+	if got := contents(storedMessages(t, cache, "user")); strings.Join(got, "|") != "m1|m2|m3" {
+		t.Fatalf("stored = %q, want all messages", got)
+	}
+	msgs, err := box.GetMemories(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := contents(msgs); strings.Join(got, "|") != "m2|m3" {
+		t.Fatalf("history = %q, want [m2 m3]", got)
+	}
+
+	// Once the summarizer recovers, everything that piled up is folded.
+	fake.err = nil
+	box.Tell(ctx, "user", "m4")
+	want := []string{memorybox.SummaryPrefix + "m1,m2", "m3", "m4"}
+	if got := contents(storedMessages(t, cache, "user")); strings.Join(got, "|") != strings.Join(want, "|") {
+		t.Fatalf("stored = %q, want %q", got, want)
+	}
+}
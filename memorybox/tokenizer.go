@@ -0,0 +1,198 @@
+package memorybox
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// messageTokenOverhead is the number of tokens a chat API typically spends on
+// the role and the framing of a single message, on top of its content.
+const messageTokenOverhead = 4
+
+// Tokenizer counts how many tokens a model would spend on a piece of text.
+type Tokenizer interface {
+	// CountTokens returns the number of tokens in text.
+	CountTokens(text string) int
+}
+
+// HeuristicTokenizer estimates token counts offline without any vocabulary.
+// ASCII words cost roughly one token per four characters, other scripts one token per two characters,
+// and every punctuation or symbol character is counted as a separate token.
+// It is intentionally pessimistic for non-English text so that budgets are not exceeded.
+type HeuristicTokenizer struct{}
+
+// CountTokens returns an estimated number of tokens in text.
+func (HeuristicTokenizer) CountTokens(text string) int {
+	tokens := 0
+	asciiRun, otherRun := 0, 0
+
+	flush := func() {
+		tokens += (asciiRun+3)/4 + (otherRun+1)/2
+		asciiRun, otherRun = 0, 0
+	}
+
+	for _, r := range text {
+		switch {
+		case unicode.IsSpace(r):
+			flush()
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if r < utf8.RuneSelf {
+				asciiRun++
+			} else {
+				otherRun++
+			}
+		default:
+			flush()
+			tokens++
+		}
+	}
+	flush()
+	return tokens
+}
+
+// BPETokenizer counts tokens with byte-level byte pair encoding, the scheme used by GPT-style models.
+// The vocabulary is loaded from a local file, so no network access is needed.
+type BPETokenizer struct {
+	ranks map[string]int // Token bytes mapped to their merge rank (lower merges first).
+}
+
+// NewBPETokenizer loads a BPE vocabulary from path.
+// The file uses the tiktoken format: one token per line, written as the base64 encoded
+// token bytes followed by a space and its rank, e.g. "IQ== 0".
+func NewBPETokenizer(path string) (*BPETokenizer, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	ranks := make(map[string]int)
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		fields := strings.Fields(text)
+		if len(fields) != 2 {
+			return nil, fmt.Errorf("bpe vocab %s:%d: expected \"<base64 token> <rank>\"", path, line)
+		}
+		token, err := base64.StdEncoding.DecodeString(fields[0])
+		if err != nil {
+			return nil, fmt.Errorf("bpe vocab %s:%d: %w", path, line, err)
+		}
+		rank, err := strconv.Atoi(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("bpe vocab %s:%d: %w", path, line, err)
+		}
+		ranks[string(token)] = rank
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(ranks) == 0 {
+		return nil, fmt.Errorf("bpe vocab %s: no tokens", path)
+	}
+	return &BPETokenizer{ranks: ranks}, nil
+}
+
+// CountTokens returns the number of BPE tokens in text.
+func (t *BPETokenizer) CountTokens(text string) int {
+	tokens := 0
+	for _, piece := range splitPieces(text) {
+		if _, ok := t.ranks[piece]; ok {
+			tokens++
+			continue
+		}
+		tokens += len(t.merge(piece))
+	}
+	return tokens
+}
+
+// merge splits piece into single bytes and repeatedly joins the adjacent pair
+// with the lowest rank until no known pair is left.
+func (t *BPETokenizer) merge(piece string) []string {
+	parts := make([]string, len(piece))
+	for i := 0; i < len(piece); i++ {
+		parts[i] = piece[i : i+1]
+	}
+
+	for len(parts) > 1 {
+		best, bestRank := -1, 0
+		for i := 0; i < len(parts)-1; i++ {
+			rank, ok := t.ranks[parts[i]+parts[i+1]]
+			if ok && (best < 0 || rank < bestRank) {
+				best, bestRank = i, rank
+			}
+		}
+		if best < 0 {
+			break
+		}
+		parts[best] += parts[best+1]
+		parts = append(parts[:best+1], parts[best+2:]...)
+	}
+	return parts
+}
+
+// splitPieces pre-tokenizes text the way GPT tokenizers do, in a simplified form:
+// words keep a single leading space, digits are grouped by three,
+// punctuation runs and whitespace runs form their own pieces.
+func splitPieces(text string) []string {
+	var pieces []string
+	for len(text) > 0 {
+		start := 0
+		if text[0] == ' ' && len(text) > 1 {
+			start = 1
+		}
+		r, size := utf8.DecodeRuneInString(text[start:])
+		end := start + size
+
+		switch {
+		case unicode.IsLetter(r):
+			for end < len(text) {
+				r, size := utf8.DecodeRuneInString(text[end:])
+				if !unicode.IsLetter(r) {
+					break
+				}
+				end += size
+			}
+		case unicode.IsDigit(r):
+			for digits := 1; digits < 3 && end < len(text); digits++ {
+				r, size := utf8.DecodeRuneInString(text[end:])
+				if !unicode.IsDigit(r) {
+					break
+				}
+				end += size
+			}
+		case unicode.IsSpace(r):
+			end = size
+			for end < len(text) {
+				r, size := utf8.DecodeRuneInString(text[end:])
+				if !unicode.IsSpace(r) {
+					break
+				}
+				end += size
+			}
+		default:
+			for end < len(text) {
+				r, size := utf8.DecodeRuneInString(text[end:])
+				if unicode.IsSpace(r) || unicode.IsLetter(r) || unicode.IsDigit(r) {
+					break
+				}
+				end += size
+			}
+		}
+
+		pieces = append(pieces, text[:end])
+		text = text[end:]
+	}
+	return pieces
+}
@@ -0,0 +1,97 @@
+package memorybox_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+func TestBPETokenizer(t *testing.T) {
+	// testdata/vocab.tiktoken: "he" 0, "ll" 1, "hell" 2, "o" 3, " world" 4, "!" 5
+	tok, err := memorybox.NewBPETokenizer(filepath.Join("testdata", "vocab.tiktoken"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		text string
+		want int
+	}{
+		{"", 0},
+		{"o", 1},              // A whole piece in the vocabulary
+		{"hello", 2},          // he + ll merge into hell, then o
+		{"hello world!", 4},   // hell, o, " world", !
+		{"héllo", 5},          // h and the two bytes of é are unknown and count one token each
+		{"hello hello", 5},    // " hello" has no merge for its leading space
+		{"hello\n\nworld", 9}, // hell, o, two unknown newline bytes and the five bytes of "world" without a space
+	}
+	for _, tt := range tests {
+		if got := tok.CountTokens(tt.text); got != tt.want {
+			t.Errorf("CountTokens(%q) = %d, want %d", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestBPETokenizerMalformedVocab(t *testing.T) {
+	tests := map[string]string{
+		"missing rank":   "aGU=\n",
+		"bad base64":     "!!! 1\n",
+		"bad rank":       "aGU= first\n",
+		"no tokens":      "\n\n",
+		"too many parts": "aGU= 0 1\n",
+	}
+	for name, vocab := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "vocab.tiktoken")
+			if err := os.WriteFile(path, []byte(vocab), 0o644); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := memorybox.NewBPETokenizer(path); err == nil {
+				t.Fatal("NewBPETokenizer accepted a malformed vocabulary")
+			}
+		})
+	}
+
+	if _, err := memorybox.NewBPETokenizer(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Fatal("NewBPETokenizer accepted a missing file")
+	}
+}
+
+// wordTokenizer counts one token per word.
+type wordTokenizer struct{}
+
+func (wordTokenizer) CountTokens(text string) int { return len(strings.Fields(text)) }
+
+func TestMaxTokensKeepsSystemAndNewest(t *testing.T) {
+	ctx := context.Background()
+	// Every message costs its words plus 4 tokens of framing.
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{
+		MaxTokens: 20,
+		Tokenizer: wordTokenizer{},
+	})
+
+	box.AddRaw(ctx, "user", memorybox.SystemRole, "be brief") // 6
+	box.Tell(ctx, "user", "one two")                          // 6
+	box.Remember(ctx, "user", "three")                        // 5
+	msgs, err := box.Tell(ctx, "user", "four five six")       // 7, over the budget by 4
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(msgs); got != "be brief|three|four five six" {
+		t.Fatalf("history = %s, want be brief|three|four five six", got)
+	}
+
+	// The newest message alone exceeds the budget and still survives, together with the system message.
+	long := strings.Repeat("word ", 30)
+	msgs, err = box.Tell(ctx, "user", long)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(msgs); got != "be brief|"+long {
+		t.Fatalf("history = %s, want the system message and the long message", got)
+	}
+}
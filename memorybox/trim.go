@@ -0,0 +1,181 @@
+package memorybox
+
+// TrimPolicy decides which messages stay in the history after every write.
+type TrimPolicy interface {
+	// Trim returns the messages that should be kept, in their original order.
+	// It must return a subsequence of msgs and should keep the newest message.
+	Trim(msgs []Message) []Message
+}
+
+// KeepSystemPolicy keeps all leading system messages and the newest messages after them,
+// so that the history holds at most MaxMessages messages. This is the default policy,
+// used with MaxMessages set to ContextLenSize when MemoryBoxConfig.TrimPolicy is nil.
+type KeepSystemPolicy struct {
+	// MaxMessages is the maximum history length including system messages. Zero means no limit.
+	MaxMessages int
+}
+
+// Trim drops the oldest non-system messages.
+func (p KeepSystemPolicy) Trim(msgs []Message) []Message {
+	if p.MaxMessages <= 0 || len(msgs) <= p.MaxMessages {
+		return msgs
+	}
+
+	system := leadingSystem(msgs)
+	last := max(p.MaxMessages-system, 1)
+	if last > len(msgs)-system {
+		last = len(msgs) - system
+	}
+
+	out := make([]Message, 0, system+last)
+	out = append(out, msgs[:system]...)
+	return append(out, msgs[len(msgs)-last:]...)
+}
+
+// TurnPolicy keeps all leading system messages and the last MaxTurns conversation turns.
+// A turn starts with a user message and includes every assistant and tool message after it,
+// so a question is never separated from its answer.
+type TurnPolicy struct {
+	// MaxTurns is the number of turns to keep. Zero means no limit.
+	MaxTurns int
+}
+
+// Trim drops the oldest whole turns.
+func (p TurnPolicy) Trim(msgs []Message) []Message {
+	if p.MaxTurns <= 0 {
+		return msgs
+	}
+
+	system := leadingSystem(msgs)
+	start := len(msgs)
+	for turns := 0; start > system && turns < p.MaxTurns; {
+		start--
+		if msgs[start].Role == UserRole {
+			turns++
+		}
+	}
+	if start == system {
+		return msgs
+	}
+
+	out := make([]Message, 0, system+len(msgs)-start)
+	out = append(out, msgs[:system]...)
+	return append(out, msgs[start:]...)
+}
+
+// FirstLastPolicy keeps the first First messages and the last Last messages and drops everything in between.
+// It is useful when the beginning of a conversation carries instructions that are not system messages.
+type FirstLastPolicy struct {
+	First int // Number of messages to keep from the beginning.
+	Last  int // Number of messages to keep from the end.
+}
+
+// Trim drops the messages in the middle of the history.
+func (p FirstLastPolicy) Trim(msgs []Message) []Message {
+	first, last := max(p.First, 0), max(p.Last, 1)
+	if first+last >= len(msgs) {
+		return msgs
+	}
+
+	out := make([]Message, 0, first+last)
+	out = append(out, msgs[:first]...)
+	return append(out, msgs[len(msgs)-last:]...)
+}
+
+// ToolSafePolicy wraps another policy and makes sure it never breaks a tool call apart from its results:
+// a tool result is dropped when the assistant message that requested it is trimmed,
+// and the results of a kept tool call are kept as well.
+// Tool messages without a ToolCallID are matched to the closest preceding assistant message.
+type ToolSafePolicy struct {
+	// Policy is the wrapped policy. KeepSystemPolicy without a limit is used when nil.
+	Policy TrimPolicy
+}
+
+// Trim applies the wrapped policy and keeps tool calls and their results together.
+func (p ToolSafePolicy) Trim(msgs []Message) []Message {
+	var inner TrimPolicy = KeepSystemPolicy{}
+	if p.Policy != nil {
+		inner = p.Policy
+	}
+
+	keep := keptMask(msgs, inner.Trim(msgs))
+
+	// Index the assistant messages by the IDs of the tool calls they made.
+	callers := map[string]int{}
+	for i, m := range msgs {
+		for _, tc := range m.ToolCalls {
+			callers[tc.ID] = i
+		}
+	}
+
+	for i, m := range msgs {
+		if m.Role != ToolRole {
+			continue
+		}
+		call, ok := callers[m.ToolCallID]
+		if m.ToolCallID == "" || !ok {
+			// Walk back over the sibling tool results to the assistant message that made the call.
+			call = i - 1
+			for call >= 0 && msgs[call].Role == ToolRole {
+				call--
+			}
+			if call < 0 || msgs[call].Role != AssistantRole {
+				keep[i] = false
+				continue
+			}
+		}
+		keep[i] = keep[call]
+	}
+
+	out := make([]Message, 0, len(msgs))
+	for i, m := range msgs {
+		if keep[i] {
+			out = append(out, m)
+		}
+	}
+	return out
+}
+
+// leadingSystem returns the number of system messages at the beginning of msgs.
+func leadingSystem(msgs []Message) int {
+	n := 0
+	for n < len(msgs) && msgs[n].Role == SystemRole {
+		n++
+	}
+	return n
+}
+
+// keptMask reports for every message in before whether it is present in after,
+// which must be a subsequence of before. Matching starts from the newest message,
+// so among equal messages the older ones are considered dropped.
+func keptMask(before, after []Message) []bool {
+	keep := make([]bool, len(before))
+	j := len(after) - 1
+	for i := len(before) - 1; i >= 0 && j >= 0; i-- {
+		if sameMessage(before[i], after[j]) {
+			keep[i] = true
+			j--
+		}
+	}
+	return keep
+}
+
+// dropped returns the messages of before that are missing in after.
+func dropped(before, after []Message) []Message {
+	var out []Message
+	for i, keep := range keptMask(before, after) {
+		if !keep {
+			out = append(out, before[i])
+		}
+	}
+	return out
+}
+
+// sameMessage reports whether a and b are the same history entry.
+// Messages are compared by ID when both have one.
+func sameMessage(a, b Message) bool {
+	if a.ID != "" && b.ID != "" {
+		return a.ID == b.ID
+	}
+	return a.Role == b.Role && a.Content == b.Content
+}
@@ -0,0 +1,94 @@
+package memorybox_test
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// conversation builds messages from "role:content" pairs, e.g. "user:q1".
+func conversation(entries ...string) []memorybox.Message {
+	msgs := make([]memorybox.Message, len(entries))
+	for i, e := range entries {
+		role, content, _ := strings.Cut(e, ":")
+		msgs[i] = memorybox.Message{Role: memorybox.Role(role), Content: content}
+	}
+	return msgs
+}
+
+func TestTrimPolicies(t *testing.T) {
+	msgs := conversation("system:s", "user:q1", "assistant:a1", "user:q2", "assistant:a2", "tool:t2", "assistant:a2b", "user:q3")
+
+	tests := []struct {
+		name   string
+		policy memorybox.TrimPolicy
+		want   string
+	}{
+		{"keep system", memorybox.KeepSystemPolicy{MaxMessages: 3}, "s|a2b|q3"},
+		{"keep system unlimited", memorybox.KeepSystemPolicy{}, "s|q1|a1|q2|a2|t2|a2b|q3"},
+		{"keep system keeps newest", memorybox.KeepSystemPolicy{MaxMessages: 1}, "s|q3"},
+		{"turns", memorybox.TurnPolicy{MaxTurns: 2}, "s|q2|a2|t2|a2b|q3"},
+		{"turns over length", memorybox.TurnPolicy{MaxTurns: 10}, "s|q1|a1|q2|a2|t2|a2b|q3"},
+		{"first last", memorybox.FirstLastPolicy{First: 2, Last: 2}, "s|q1|a2b|q3"},
+		{"first last overlap", memorybox.FirstLastPolicy{First: 5, Last: 5}, "s|q1|a1|q2|a2|t2|a2b|q3"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := history(tt.policy.Trim(msgs)); got != tt.want {
+				t.Errorf("Trim = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestToolSafePolicy(t *testing.T) {
+	msgs := conversation("system:s", "user:q1", "assistant:call", "tool:r1", "tool:r2", "assistant:a1", "user:q2")
+	msgs[2].ToolCalls = []memorybox.ToolCall{{ID: "c1"}, {ID: "c2"}}
+	msgs[3].ToolCallID = "c1"
+	msgs[4].ToolCallID = "c2"
+
+	tests := []struct {
+		name  string
+		inner memorybox.TrimPolicy
+		want  string
+	}{
+		// The wrapped policy cuts between the two results: the call is gone, so both results go too.
+		{"drops orphaned results", memorybox.KeepSystemPolicy{MaxMessages: 4}, "s|a1|q2"},
+		// The call is kept: its results are kept with it even though the wrapped policy dropped them.
+		{"keeps results of a kept call", memorybox.FirstLastPolicy{First: 3, Last: 2}, "s|q1|call|r1|r2|a1|q2"},
+		{"nil policy keeps everything", nil, "s|q1|call|r1|r2|a1|q2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := history(memorybox.ToolSafePolicy{Policy: tt.inner}.Trim(msgs))
+			if got != tt.want {
+				t.Errorf("Trim = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	// Results without a ToolCallID belong to the closest preceding assistant message.
+	legacy := conversation("user:q1", "assistant:call", "tool:r1", "tool:r2", "user:q2")
+	if got := history(memorybox.ToolSafePolicy{Policy: memorybox.KeepSystemPolicy{MaxMessages: 2}}.Trim(legacy)); got != "q2" {
+		t.Errorf("Trim without tool call IDs = %s, want q2", got)
+	}
+}
+
+func TestTrimPolicyAppliedOnWrite(t *testing.T) {
+	box := memorybox.NewMemoryBox(memorybox.NewCache(), memorybox.MemoryBoxConfig{
+		TrimPolicy: memorybox.TurnPolicy{MaxTurns: 1},
+	})
+	ctx := context.Background()
+	box.Tell(ctx, "user", "q1")
+	box.Remember(ctx, "user", "a1")
+	box.Tell(ctx, "user", "q2")
+	msgs, err := box.Remember(ctx, "user", "a2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := history(msgs); got != "q2|a2" {
+		t.Errorf("history = %s, want q2|a2", got)
+	}
+}
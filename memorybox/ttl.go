@@ -0,0 +1,83 @@
+package memorybox
+
+import (
+	"context"
+	"time"
+)
+
+// TTLMode controls when the TTL of a stored conversation starts over.
+type TTLMode int
+
+const (
+	// TTLSlidingWrite resets the TTL on every write; reads do not extend it. This is the default.
+	TTLSlidingWrite TTLMode = iota
+
+	// TTLAbsolute sets the TTL when the conversation is created; later writes keep it,
+	// so a conversation lives for a fixed time no matter how active it is.
+	TTLAbsolute
+
+	// TTLSlidingReadWrite resets the TTL on every write and every read of the history.
+	TTLSlidingReadWrite
+)
+
+// KeepTTL passed as the expiration to Set or Update keeps the current TTL of an existing key;
+// a new key is stored without a TTL. It has the same value as redis.KeepTTL.
+const KeepTTL time.Duration = -1
+
+// IExpirer is an optional extension of IMemorizer for backends that can change the TTL of a key
+// without rewriting it. TTLAbsolute and TTLSlidingReadWrite need it; with other backends
+// they behave like TTLSlidingWrite. Backends implementing IExpirer must also accept KeepTTL.
+type IExpirer interface {
+	// Expire sets the TTL of key to expiration; zero or less removes the TTL.
+	// Expiring a missing key is not an error.
+	Expire(ctx context.Context, key string, expiration time.Duration) error
+
+	// GetEx returns the value of key like Get and sets its TTL like Expire.
+	GetEx(ctx context.Context, key string, expiration time.Duration) (string, error)
+}
+
+type ttlContextKey struct{}
+
+// ContextWithTTL returns a context that makes MemoryBox calls made with it store the conversation
+// with ttl instead of the configured TTL, e.g. for a single long-lived support thread.
+// Zero means no expiration.
+func ContextWithTTL(ctx context.Context, ttl time.Duration) context.Context {
+	return context.WithValue(ctx, ttlContextKey{}, ttl)
+}
+
+// ttl returns the TTL for key: the per-call override from the context, then TTLFunc, then ExpireTime.
+func (b *MemoryBox) ttl(ctx context.Context, key string) time.Duration {
+	if ttl, ok := ctx.Value(ttlContextKey{}).(time.Duration); ok {
+		return ttl
+	}
+	if b.TTLFunc != nil {
+		return b.TTLFunc(KeyUser(key))
+	}
+	return b.ExpireTime
+}
+
+// expirer returns the IExpirer of the store if the TTL mode needs it and the store implements it.
+func (b *MemoryBox) expirer(mode TTLMode) (IExpirer, bool) {
+	if mode == TTLSlidingWrite {
+		return nil, false
+	}
+	e, ok := b.IMemorizer.(IExpirer)
+	return e, ok
+}
+
+// read returns the raw value stored under key, extending its TTL in TTLSlidingReadWrite mode.
+// Reading a session history extends the user's session index as well, so the session stays listed.
+func (b *MemoryBox) read(ctx context.Context, key string) (string, error) {
+	ttl := b.ttl(ctx, key)
+	e, ok := b.expirer(b.TTLMode)
+	if b.TTLMode != TTLSlidingReadWrite || !ok || ttl <= 0 {
+		return b.Get(ctx, key)
+	}
+
+	if userid, _, isSession := ParseSessionKey(key); isSession {
+		if err := e.Expire(ctx, userid+sessionIndexSuffix, ttl); err != nil {
+			return "", err
+		}
+	}
+	return e.GetEx(ctx, key, ttl)
+}
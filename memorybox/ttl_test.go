@@ -0,0 +1,96 @@
+package memorybox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+	"github.com/rmay1er/magic-memory-box-go/memorybox/clocktest"
+)
+
+// newClockedBox returns a MemoryBox over a cache driven by a fake clock.
+func newClockedBox(cfg memorybox.MemoryBoxConfig) (memorybox.IMemoryBox, *clocktest.Clock) {
+	clock := clocktest.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	cfg.Clock = clock
+	return memorybox.NewMemoryBox(memorybox.NewCache(memorybox.WithClock(clock)), cfg), clock
+}
+
+// wantAlive fails the test if the user's history has expired.
+func wantAlive(t *testing.T, box memorybox.IMemoryBox, userid string) {
+	t.Helper()
+	if _, err := box.GetMemories(context.Background(), userid); err != nil {
+		t.Fatalf("GetMemories(%s) error = %v, want the history", userid, err)
+	}
+}
+
+// wantExpired fails the test unless the user's history has expired.
+func wantExpired(t *testing.T, box memorybox.IMemoryBox, userid string) {
+	t.Helper()
+	if _, err := box.GetMemories(context.Background(), userid); !errors.Is(err, memorybox.ErrNotFound) {
+		t.Fatalf("GetMemories(%s) error = %v, want ErrNotFound", userid, err)
+	}
+}
+
+func TestTTLSlidingWrite(t *testing.T) {
+	ctx := context.Background()
+	box, clock := newClockedBox(memorybox.MemoryBoxConfig{ExpireTime: 10 * time.Minute})
+
+	box.Tell(ctx, "user", "q1")
+	clock.Advance(8 * time.Minute)
+	box.Tell(ctx, "user", "q2") // Writes restart the TTL
+	clock.Advance(8 * time.Minute)
+	wantAlive(t, box, "user") // Reads do not
+	clock.Advance(3 * time.Minute)
+	wantExpired(t, box, "user")
+}
+
+func TestTTLAbsolute(t *testing.T) {
+	ctx := context.Background()
+	box, clock := newClockedBox(memorybox.MemoryBoxConfig{ExpireTime: 10 * time.Minute, TTLMode: memorybox.TTLAbsolute})
+
+	box.Tell(ctx, "user", "q1")
+	clock.Advance(8 * time.Minute)
+	box.Tell(ctx, "user", "q2")
+	clock.Advance(time.Minute)
+	wantAlive(t, box, "user")
+	clock.Advance(2 * time.Minute)
+	wantExpired(t, box, "user")
+}
+
+func TestTTLSlidingReadWrite(t *testing.T) {
+	ctx := context.Background()
+	box, clock := newClockedBox(memorybox.MemoryBoxConfig{ExpireTime: 10 * time.Minute, TTLMode: memorybox.TTLSlidingReadWrite})
+
+	box.Tell(ctx, "user", "q1")
+	clock.Advance(8 * time.Minute)
+	wantAlive(t, box, "user")
+	clock.Advance(8 * time.Minute)
+	wantAlive(t, box, "user")
+	clock.Advance(11 * time.Minute)
+	wantExpired(t, box, "user")
+}
+
+func TestTTLOverrides(t *testing.T) {
+	box, clock := newClockedBox(memorybox.MemoryBoxConfig{
+		ExpireTime: 10 * time.Minute,
+		TTLFunc: func(userid string) time.Duration {
+			if userid == "paid" {
+				return time.Hour
+			}
+			return time.Minute
+		},
+	})
+
+	ctx := context.Background()
+	box.Tell(ctx, "free", "q1")
+	box.Tell(ctx, "paid", "q1")
+	box.Tell(memorybox.ContextWithTTL(ctx, 0), "support", "q1") // The context overrides TTLFunc; zero never expires
+	clock.Advance(2 * time.Minute)
+	wantExpired(t, box, "free")
+	wantAlive(t, box, "paid")
+	clock.Advance(time.Hour)
+	wantExpired(t, box, "paid")
+	wantAlive(t, box, "support")
+}
@@ -1,7 +1,9 @@
 package memorybox
 
 import (
+	"os"
 	"sync"
+	"sync/atomic"
 	"time"
 )
 
@@ -9,20 +11,91 @@ import (
 type MapFields struct {
 	Value      any       // The stored value in the cache.
 	ExpireTime time.Time // The time when the value expires.
+	LastAccess time.Time // The time of the last read or write, used by eviction policies.
+	Hits       uint64    // Number of reads and writes, used by eviction policies.
 }
 
 // inCache represents an in-memory cache using a map.
 type MemoryCache struct {
 	memory map[string]MapFields // The cache storage mapping keys to cached values.
 	mu     sync.RWMutex         // Mutex to protect concurrent access to memory.
+
+	janitorInterval time.Duration  // How often the janitor removes expired keys; zero disables it.
+	stop            chan struct{}  // Closed by Close to stop the janitor.
+	closeOnce       sync.Once      // Makes Close idempotent.
+	background      sync.WaitGroup // Tracks the janitor and persistence goroutines.
+
+	maxEntries  int            // Maximum number of keys; zero means unlimited.
+	maxBytes    int64          // Maximum total size of keys and values; zero means unlimited.
+	eviction    EvictionPolicy // Chooses the key to drop when a limit is exceeded.
+	order       *evictionQueue // Keys in eviction order; nil when the cache is unbounded.
+	bytes       int64          // Current total size of keys and values.
+	evictions   uint64         // Number of keys dropped because of the limits.
+	expirations uint64         // Number of keys removed because their TTL passed.
+
+	dir              string        // Persistence directory; empty for a purely in-memory cache.
+	wal              *os.File      // Append-only write log, nil without persistence.
+	fsync            FsyncPolicy   // How often the write log is synced to disk.
+	snapshotInterval time.Duration // How often a snapshot is written and the log truncated.
+	dirty            atomic.Bool   // Set when the write log has unsynced writes.
+
+	onExpire func(key, value string) // Called after a key is removed because its TTL passed.
+	onEvict  func(key, value string) // Called after a key is dropped because the cache is full.
+	events   []func()                // Callbacks queued while the write lock is held.
+
+	clock Clock // Source of time for expiry and eviction, SystemClock by default.
 }
 
+// ShardedCache is an in-memory cache split into independently locked MemoryCache shards.
+type ShardedCache struct {
+	shards []*MemoryCache // Keys are assigned to shards by hash.
+}
+
+// CacheOption configures a MemoryCache created by NewCache.
+type CacheOption func(*MemoryCache)
+
 type MemoryBoxConfig struct {
-	// ContextLenSize defines the size of the context length.
+	// ContextLenSize defines the size of the context length: the maximum number of messages
+	// kept by the default KeepSystemPolicy. Zero means no limit.
 	ContextLenSize int
 
+	// TrimPolicy decides which messages are kept after every write.
+	// KeepSystemPolicy{MaxMessages: ContextLenSize} is used when nil.
+	TrimPolicy TrimPolicy
+
 	// ExpireTime defines the expiration duration for stored memories.
 	ExpireTime time.Duration
+
+	// TTLMode controls whether writes and reads extend ExpireTime. TTLSlidingWrite is the default.
+	TTLMode TTLMode
+
+	// TTLFunc, if set, returns the TTL for a user and replaces ExpireTime,
+	// e.g. to keep the memory of users on paid plans longer. ContextWithTTL overrides both.
+	TTLFunc func(userid string) time.Duration
+
+	// MaxTokens limits the token size of the history. When it is greater than zero,
+	// the oldest non-system messages are dropped until the history fits.
+	MaxTokens int
+
+	// Tokenizer counts tokens for MaxTokens. HeuristicTokenizer is used when nil.
+	Tokenizer Tokenizer
+
+	// StreamCheckpoint is how often RememberStream and RememberChunks save a partial reply.
+	// One second is used when zero.
+	StreamCheckpoint time.Duration
+
+	// Summarizer, if set, folds trimmed messages into a rolling summary message
+	// placed right after the leading system messages instead of discarding them.
+	Summarizer Summarizer
+
+	// SummaryBatch is how many trimmed messages are collected before the Summarizer is called,
+	// so it does not run on every write once the history is full. Until then the trimmed
+	// messages stay in the store but not in the returned history. Four is used when zero.
+	SummaryBatch int
+
+	// Clock is the source of time for message timestamps and stream checkpoints.
+	// SystemClock is used when nil. The store keeps its own clock for expiry, see WithClock.
+	Clock Clock
 }
 
 type Role string
@@ -35,7 +108,32 @@ const (
 )
 
 // Message represents a chat message with a role and content.
+// Histories stored before the json tags were added ("Role"/"Content" keys, no ID) still load:
+// missing IDs are assigned on the next write.
 type Message struct {
-	Role    Role   // Role of the message sender
-	Content string // Content of the message
+	ID        string         `json:"id,omitempty"`        // Unique, time-sortable message ID (ULID)
+	Role      Role           `json:"role"`                // Role of the message sender
+	Content   string         `json:"content"`             // Content of the message
+	Name      string         `json:"name,omitempty"`      // Optional participant name for multi-party chats
+	CreatedAt time.Time      `json:"created_at,omitzero"` // When the message was added
+	Metadata  map[string]any `json:"metadata,omitempty"`  // Free-form data, e.g. model name, latency or request ID
+
+	ParentID   string     `json:"parent_id,omitempty"`    // ID of the previous message in the conversation tree
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // Tools requested by an assistant message
+	ToolCallID string     `json:"tool_call_id,omitempty"` // For tool messages: the ID of the call this result answers
+}
+
+// ToolCall is a tool invocation requested by the assistant.
+type ToolCall struct {
+	ID        string `json:"id"`        // Call ID, echoed back in the ToolCallID of the result message
+	Name      string `json:"name"`      // Name of the tool
+	Arguments string `json:"arguments"` // Tool arguments as a JSON string
+}
+
+// Session describes one of several conversations of a user.
+type Session struct {
+	ID         string    `json:"id"`          // Unique session identifier
+	Title      string    `json:"title"`       // Human readable title, e.g. for a chat sidebar
+	CreatedAt  time.Time `json:"created_at"`  // When the session was created
+	LastActive time.Time `json:"last_active"` // When a message was last added to the session
 }
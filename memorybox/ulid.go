@@ -0,0 +1,83 @@
+package memorybox
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// crockford is the Crockford base32 alphabet used by ULIDs.
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidState keeps the last generated ULID so that IDs created within
+// the same millisecond stay strictly increasing.
+var ulidState struct {
+	mu      sync.Mutex
+	lastMs  uint64
+	entropy [10]byte
+}
+
+// NewULID returns a new ULID: a 26 character, lexicographically sortable identifier
+// made of a millisecond timestamp and 80 random bits.
+// IDs generated by one process are monotonic even within the same millisecond.
+func NewULID() string {
+	return newULID(time.Now())
+}
+
+// newULID returns a ULID for the given time.
+func newULID(now time.Time) string {
+	ms := uint64(now.UnixMilli())
+
+	ulidState.mu.Lock()
+	if ms <= ulidState.lastMs {
+		// Same (or earlier) millisecond: increment the previous entropy instead of drawing new bits.
+		ms = ulidState.lastMs
+		for i := len(ulidState.entropy) - 1; i >= 0; i-- {
+			ulidState.entropy[i]++
+			if ulidState.entropy[i] != 0 {
+				break
+			}
+		}
+	} else {
+		rand.Read(ulidState.entropy[:])
+		ulidState.lastMs = ms
+	}
+	var raw [16]byte
+	raw[0], raw[1], raw[2] = byte(ms>>40), byte(ms>>32), byte(ms>>24)
+	raw[3], raw[4], raw[5] = byte(ms>>16), byte(ms>>8), byte(ms)
+	copy(raw[6:], ulidState.entropy[:])
+	ulidState.mu.Unlock()
+	return encodeULID(raw)
+}
+
+// encodeULID encodes the 16 bytes of a ULID as text.
+func encodeULID(raw [16]byte) string {
+	// 128 bits are encoded as 26 base32 characters, the first one carrying only 3 bits.
+	var out [26]byte
+	var acc uint32
+	bits := 2 // Pad the front so that 130 bits split evenly into 5-bit groups.
+	pos := 0
+	for _, b := range raw {
+		acc = acc<<8 | uint32(b)
+		bits += 8
+		for bits >= 5 {
+			bits -= 5
+			out[pos] = crockford[(acc>>bits)&31]
+			pos++
+		}
+	}
+	return string(out[:])
+}
+
+// legacyID returns a stable ID for a message that an older version stored without one.
+// It is derived from key, which identifies the message content, and from the number n
+// of identical messages before it, so every read of the same history yields the same IDs
+// until the next write saves them. The timestamp part is zero: such messages sort before all others.
+func legacyID(key string, n int) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d\x00%s", n, key))
+	var raw [16]byte
+	copy(raw[6:], sum[:10])
+	return encodeULID(raw)
+}
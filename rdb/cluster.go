@@ -0,0 +1,89 @@
+package rdb
+
+import (
+	"context"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// WithHashTags заключает ID пользователя в ключах в фигурные скобки (hash tag): "chat:{user}\x1fsession:…".
+// В Redis Cluster все ключи пользователя тогда попадают в один слот, и многоключевые операции
+// над ними (MULTI с теневой копией, DEL нескольких ключей) выполняются атомарно на одном узле.
+// Включение меняет имена ключей: данные, записанные без этой опции, адаптер больше не увидит.
+// ID пользователей не должны содержать "}". Для *redis.ClusterClient с WithExpiryShadow опция включается сама.
+func WithHashTags() Option {
+	return func(r *RedisAdapter) {
+		r.hashTags = true
+	}
+}
+
+// scanCount — подсказка Redis о размере страницы SCAN.
+const scanCount = 1000
+
+// scan вызывает fn для каждого ключа под префиксом адаптера. В кластере обходятся все мастер-узлы,
+// в Ring — все шарды; fn тогда вызывается параллельно из нескольких горутин.
+func (r *RedisAdapter) scan(ctx context.Context, fn func(k string) error) error {
+	return r.scanPages(ctx, func(keys []string) error {
+		for _, k := range keys {
+			if err := fn(k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// scanPages как scan, но передаёт в fn сразу всю страницу SCAN одного узла.
+func (r *RedisAdapter) scanPages(ctx context.Context, fn func(keys []string) error) error {
+	scanNode := func(ctx context.Context, node redis.Cmdable) error {
+		var cursor uint64
+		for {
+			keys, next, err := node.Scan(ctx, cursor, r.prefix+"*", scanCount).Result()
+			if err != nil {
+				return err
+			}
+			if len(keys) > 0 {
+				if err := fn(keys); err != nil {
+					return err
+				}
+			}
+			if next == 0 {
+				return nil
+			}
+			cursor = next
+		}
+	}
+	each := func(ctx context.Context, node *redis.Client) error {
+		return scanNode(ctx, node)
+	}
+
+	switch c := r.client.(type) {
+	case *redis.ClusterClient:
+		return c.ForEachMaster(ctx, each)
+	case *redis.Ring:
+		return c.ForEachShard(ctx, each)
+	}
+	return scanNode(ctx, r.client)
+}
+
+// key возвращает полный ключ Redis: префикс и ключ memorybox. С WithHashTags ID пользователя
+// заключается в {}, так что все ключи пользователя (история, сессии, индекс, теневые копии) попадают в один слот.
+func (r *RedisAdapter) key(key string) string {
+	if !r.hashTags {
+		return r.prefix + key
+	}
+	user := memorybox.KeyUser(key)
+	return r.prefix + "{" + user + "}" + strings.TrimPrefix(key, user)
+}
+
+// unkey превращает полный ключ Redis обратно в ключ memorybox. ok == false для чужих ключей.
+func (r *RedisAdapter) unkey(k string) (string, bool) {
+	key, ok := strings.CutPrefix(k, r.prefix)
+	if !ok || !r.hashTags {
+		return key, ok
+	}
+	user, rest, ok := strings.Cut(strings.TrimPrefix(key, "{"), "}")
+	return user + rest, ok && strings.HasPrefix(key, "{")
+}
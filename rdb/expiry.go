@@ -0,0 +1,179 @@
+package rdb
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// notifyConfig — параметр сервера с классами keyspace-уведомлений.
+const notifyConfig = "notify-keyspace-events"
+
+// defaultShadowGrace — запас жизни теневой копии, если в WithExpiryShadow передан ноль.
+const defaultShadowGrace = time.Hour
+
+// WithExpiryShadow включает теневые копии: для каждого ключа с TTL рядом хранится копия значения,
+// которая живёт на grace дольше. Когда основной ключ истекает, ListenExpired забирает значение из копии.
+// Без запущенного слушателя копии сами удаляются через grace после основного ключа.
+// Копия пишется в одной транзакции с ключом, поэтому для *redis.ClusterClient опция включает WithHashTags.
+func WithExpiryShadow(grace time.Duration) Option {
+	return func(r *RedisAdapter) {
+		if grace <= 0 {
+			grace = defaultShadowGrace
+		}
+		r.expiryShadow = true
+		r.shadowGrace = grace
+	}
+}
+
+// WithManagedNotifications сообщает, что notify-keyspace-events настроены на сервере заранее
+// (например, в параметрах управляемого Redis), и ListenExpired не должен выполнять CONFIG.
+func WithManagedNotifications() Option {
+	return func(r *RedisAdapter) {
+		r.managedNotify = true
+	}
+}
+
+// setShadow добавляет в pipe запись теневой копии; для ключей без TTL копия удаляется,
+// при redis.KeepTTL копия сохраняет свой TTL.
+func (r *RedisAdapter) setShadow(ctx context.Context, pipe redis.Pipeliner, key string, value any, exp time.Duration) {
+	shadow := r.key(key) + shadowSuffix
+	switch {
+	case exp == redis.KeepTTL:
+		pipe.Set(ctx, shadow, value, redis.KeepTTL)
+		r.keepEphemeral(ctx, pipe, shadow, exp, r.ephemeral+r.shadowGrace)
+	case exp <= 0:
+		pipe.Del(ctx, shadow)
+	default:
+		pipe.Set(ctx, shadow, value, exp+r.shadowGrace)
+	}
+}
+
+// expireShadow добавляет в pipe изменение TTL теневой копии вслед за основным ключом.
+func (r *RedisAdapter) expireShadow(ctx context.Context, pipe redis.Pipeliner, key string, exp time.Duration) {
+	shadow := r.key(key) + shadowSuffix
+	if exp <= 0 {
+		pipe.Del(ctx, shadow)
+		return
+	}
+	pipe.Expire(ctx, shadow, exp+r.shadowGrace)
+}
+
+// ListenExpired подписывается на keyspace-уведомления Redis об истечении ключей
+// и вызывает fn с ключом (без префикса) и последним значением каждого истёкшего ключа адаптера.
+// Требует WithExpiryShadow и notify-keyspace-events с флагами "Ex": метод дописывает их к уже включённым
+// флагам каждого узла и возвращает ошибку, если CONFIG недоступен. На управляемых Redis, где CONFIG
+// запрещён, включите флаги в настройках сервера и создайте адаптер с WithManagedNotifications.
+// Значение забирается через GETDEL, поэтому при нескольких слушателях fn вызывается один раз.
+// В кластере и Ring уведомления приходят от каждого узла отдельно, поэтому метод подписывается
+// на все мастер-узлы (шарды), известные на момент вызова, и fn вызывается из нескольких горутин.
+// Блокирует до отмены ctx и возвращает ctx.Err(). Ошибка подписки на любом узле останавливает
+// слушателей остальных узлов и сразу возвращается.
+func (r *RedisAdapter) ListenExpired(ctx context.Context, fn func(key, value string)) error {
+	var forEach func(context.Context, func(context.Context, *redis.Client) error) error
+	switch c := r.client.(type) {
+	case *redis.ClusterClient:
+		forEach = c.ForEachMaster
+	case *redis.Ring:
+		forEach = c.ForEachShard
+	default:
+		return r.listenNode(ctx, r.client, fn)
+	}
+
+	// ForEachMaster и ForEachShard ждут все узлы: без отмены ошибка одного узла вернулась бы только вместе с ctx
+	nodesCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var (
+		once     sync.Once
+		firstErr error
+	)
+	err := forEach(nodesCtx, func(nodesCtx context.Context, node *redis.Client) error {
+		err := r.listenNode(nodesCtx, node, fn)
+		if err != nil && ctx.Err() == nil {
+			once.Do(func() {
+				firstErr = err
+				cancel()
+			})
+		}
+		return err
+	})
+	if firstErr != nil {
+		return firstErr
+	}
+	return err
+}
+
+// listenNode слушает уведомления об истечении ключей одного узла.
+func (r *RedisAdapter) listenNode(ctx context.Context, node redis.UniversalClient, fn func(key, value string)) error {
+	if !r.managedNotify {
+		if err := enableExpiredEvents(ctx, node); err != nil {
+			return err
+		}
+	}
+
+	pubsub := node.PSubscribe(ctx, "__keyevent@*__:expired")
+	defer pubsub.Close()
+	if _, err := pubsub.Receive(ctx); err != nil {
+		return err
+	}
+
+	ch := pubsub.Channel()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case msg, ok := <-ch:
+			if !ok {
+				return ctx.Err()
+			}
+			full := msg.Payload
+			key, ours := r.unkey(full)
+			if !ours || strings.HasSuffix(full, shadowSuffix) {
+				continue
+			}
+			value, err := r.client.GetDel(ctx, full+shadowSuffix).Result()
+			if err != nil {
+				// Копии нет (ключ без TTL, теневые копии выключены) или её уже забрал другой слушатель
+				continue
+			}
+			fn(key, value)
+		}
+	}
+}
+
+// enableExpiredEvents дописывает флаги "E" и "x" к notify-keyspace-events узла,
+// сохраняя классы уведомлений, которые уже включены на сервере.
+func enableExpiredEvents(ctx context.Context, node redis.UniversalClient) error {
+	vals, err := node.ConfigGet(ctx, notifyConfig).Result()
+	if err != nil {
+		return fmt.Errorf("rdb: read %s: %w", notifyConfig, err)
+	}
+	var flags string
+	if len(vals) == 2 {
+		flags, _ = vals[1].(string)
+	}
+
+	merged := withExpiredEvents(flags)
+	if merged == flags {
+		return nil
+	}
+	if err := node.ConfigSet(ctx, notifyConfig, merged).Err(); err != nil {
+		return fmt.Errorf("rdb: set %s: %w", notifyConfig, err)
+	}
+	return nil
+}
+
+// withExpiredEvents добавляет к флагам notify-keyspace-events недостающие "E" и "x".
+func withExpiredEvents(flags string) string {
+	if !strings.Contains(flags, "E") {
+		flags += "E"
+	}
+	if !strings.ContainsAny(flags, "xA") { // "A" включает все классы событий, в том числе "x"
+		flags += "x"
+	}
+	return flags
+}
@@ -0,0 +1,78 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+func TestWithExpiredEvents(t *testing.T) {
+	tests := []struct {
+		flags string
+		want  string
+	}{
+		{"", "Ex"},
+		{"Ex", "Ex"},
+		{"xE", "xE"},
+		{"KEA", "KEA"},
+		{"Kg", "KgEx"},
+		{"Elg", "Elgx"},
+		{"Kx", "KxE"},
+	}
+	for _, tt := range tests {
+		if got := withExpiredEvents(tt.flags); got != tt.want {
+			t.Errorf("withExpiredEvents(%q) = %q, want %q", tt.flags, got, tt.want)
+		}
+	}
+}
+
+func TestClusterShadowEnablesHashTags(t *testing.T) {
+	cluster := redis.NewClusterClient(&redis.ClusterOptions{Addrs: []string{"127.0.0.1:1"}})
+	defer cluster.Close()
+	single := redis.NewClient(&redis.Options{Addr: "127.0.0.1:1"})
+	defer single.Close()
+
+	if r := NewRedisAdapter(cluster, "chat:", WithExpiryShadow(0)); !r.hashTags {
+		t.Error("cluster adapter with WithExpiryShadow has no hash tags")
+	}
+	if r := NewRedisAdapter(cluster, "chat:"); r.hashTags {
+		t.Error("cluster adapter without WithExpiryShadow has hash tags")
+	}
+	if r := NewRedisAdapter(single, "chat:", WithExpiryShadow(0)); r.hashTags {
+		t.Error("single-node adapter with WithExpiryShadow has hash tags")
+	}
+}
+
+func TestListenExpiredNodeError(t *testing.T) {
+	up := miniredis.RunT(t)
+	// Адрес, на котором никто не слушает: подписка на этом шарде сразу завершится ошибкой
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := ln.Addr().String()
+	ln.Close()
+
+	ring := redis.NewRing(&redis.RingOptions{Addrs: map[string]string{"up": up.Addr(), "down": down}})
+	defer ring.Close()
+	r := NewRedisAdapter(ring, "chat:", WithExpiryShadow(0), WithManagedNotifications())
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	errc := make(chan error, 1)
+	go func() { errc <- r.ListenExpired(ctx, func(key, value string) {}) }()
+
+	select {
+	case err := <-errc:
+		if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("ListenExpired = %v, want the subscribe error of the down shard", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("ListenExpired did not return the subscribe error of the down shard")
+	}
+}
@@ -0,0 +1,94 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// errEmptyPrefix защищает от очистки всей базы адаптером без префикса.
+var errEmptyPrefix = errors.New("rdb: flush requires a non-empty prefix")
+
+// WithFlushOnClose включает удаление всех ключей под префиксом адаптера в Close.
+// Подходит для временных пространств имён (тесты, демо, одноразовые воркеры); если процесс
+// упадёт до Close, ключи останутся — вместе с опцией стоит использовать WithEphemeral.
+func WithFlushOnClose() Option {
+	return func(r *RedisAdapter) {
+		r.flushOnClose = true
+	}
+}
+
+// WithEphemeral делает пространство имён временным: ключи, которые записываются без TTL,
+// получают ttl, а снятие TTL (Expire с нулём) заменяется на ttl. Так данные исчезают сами,
+// даже если приложение не успело вызвать Close. Запись с redis.KeepTTL сохраняет текущий TTL ключа,
+// а ключ без TTL (новый или записанный до включения опции) получает ttl.
+func WithEphemeral(ttl time.Duration) Option {
+	return func(r *RedisAdapter) {
+		r.ephemeral = ttl
+	}
+}
+
+// ttl подставляет TTL временного пространства имён вместо «без TTL».
+func (r *RedisAdapter) ttl(exp time.Duration) time.Duration {
+	if r.ephemeral > 0 && exp <= 0 && exp != redis.KeepTTL {
+		return r.ephemeral
+	}
+	return exp
+}
+
+// expireKeptScript задаёт TTL ARGV[1] (мс) ключу, у которого TTL нет; ключ с TTL и отсутствующий ключ не меняются.
+var expireKeptScript = redis.NewScript(`
+if redis.call('PTTL', KEYS[1]) == -1 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+return 1
+`)
+
+// keepEphemeral добавляет в pipe TTL временного пространства имён для ключа k, записанного с redis.KeepTTL:
+// сохранять нечего, если TTL у ключа не было, и без этого ключ остался бы вечным. ttl — TTL для такого ключа.
+func (r *RedisAdapter) keepEphemeral(ctx context.Context, pipe redis.Pipeliner, k string, exp, ttl time.Duration) {
+	if r.ephemeral > 0 && exp == redis.KeepTTL {
+		expireKeptScript.Eval(ctx, pipe, []string{k}, ttlArg(ttl))
+	}
+}
+
+// Flush удаляет все ключи под префиксом адаптера; в кластере и Ring — на всех узлах.
+// Ключи удаляются через UNLINK по странице SCAN за раз, память освобождается сервером в фоне.
+// Адаптер без префикса возвращает ошибку, чтобы не очистить чужие данные.
+func (r *RedisAdapter) Flush(ctx context.Context) error {
+	if r.prefix == "" {
+		return errEmptyPrefix
+	}
+	return r.scanPages(ctx, func(keys []string) error {
+		return r.unlink(ctx, keys)
+	})
+}
+
+// unlink удаляет ключи за один запрос к Redis.
+func (r *RedisAdapter) unlink(ctx context.Context, keys []string) error {
+	switch r.client.(type) {
+	case *redis.ClusterClient, *redis.Ring:
+		// Ключи одной страницы лежат в разных слотах, а многоключевой UNLINK требует одного слота (CROSSSLOT);
+		// в конвейере клиент сам отправляет каждый ключ на его узел
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, k := range keys {
+				pipe.Unlink(ctx, k)
+			}
+			return nil
+		})
+		return err
+	}
+	return r.client.Unlink(ctx, keys...).Err()
+}
+
+// Close завершает работу адаптера: с WithFlushOnClose удаляет его ключи через Flush.
+// Клиент Redis принадлежит приложению и не закрывается. Вызывайте Close из своего
+// сценария graceful shutdown, с ctx, ограничивающим время очистки.
+func (r *RedisAdapter) Close(ctx context.Context) error {
+	if !r.flushOnClose {
+		return nil
+	}
+	return r.Flush(ctx)
+}
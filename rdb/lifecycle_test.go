@@ -0,0 +1,109 @@
+package rdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-redis/redis/v8"
+)
+
+// newTestAdapter запускает miniredis и создаёт над ним адаптер с префиксом "test:".
+func newTestAdapter(t *testing.T, opts ...Option) (*RedisAdapter, *miniredis.Miniredis) {
+	t.Helper()
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewRedisAdapter(client, "test:", opts...), mr
+}
+
+func TestEphemeralKeepTTL(t *testing.T) {
+	ctx := context.Background()
+	history := `[{"role":"user","content":"hi"}]`
+
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{"strings", nil},
+		{"lists", []Option{WithNativeLists()}},
+		{"shadow", []Option{WithExpiryShadow(time.Minute)}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, mr := newTestAdapter(t, append(tt.opts, WithEphemeral(time.Hour))...)
+
+			if err := r.Set(ctx, "new", history, redis.KeepTTL); err != nil {
+				t.Fatal(err)
+			}
+			if got := mr.TTL("test:new"); got != time.Hour {
+				t.Errorf("Set with KeepTTL on a new key: TTL = %v, want %v", got, time.Hour)
+			}
+
+			if err := r.Set(ctx, "kept", history, 10*time.Minute); err != nil {
+				t.Fatal(err)
+			}
+			err := r.Update(ctx, "kept", func(old string, exists bool) (string, error) { return history, nil }, redis.KeepTTL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := mr.TTL("test:kept"); got != 10*time.Minute {
+				t.Errorf("Update with KeepTTL on a key with TTL: TTL = %v, want %v", got, 10*time.Minute)
+			}
+
+			// Ключ без TTL, записанный до включения WithEphemeral
+			mr.Set("test:old", history)
+			err = r.Update(ctx, "old", func(old string, exists bool) (string, error) { return history, nil }, redis.KeepTTL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := mr.TTL("test:old"); got != time.Hour {
+				t.Errorf("Update with KeepTTL on a key without TTL: TTL = %v, want %v", got, time.Hour)
+			}
+		})
+	}
+}
+
+func TestEphemeralListsWithoutTTL(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestAdapter(t, WithNativeLists(), WithEphemeral(time.Hour))
+
+	mr.Set("test:old", `[{"role":"user","content":"hi"}]`)
+	if n, err := r.MigrateToLists(ctx); err != nil || n != 1 {
+		t.Fatalf("MigrateToLists = %d, %v, want 1", n, err)
+	}
+	if got := mr.TTL("test:old"); got != time.Hour {
+		t.Errorf("MigrateToLists: TTL = %v, want %v", got, time.Hour)
+	}
+
+	if _, ok, err := r.Append(ctx, "new", []string{`{"role":"user","content":"hi"}`}, 0, redis.KeepTTL); err != nil || !ok {
+		t.Fatalf("Append = %v, %v", ok, err)
+	}
+	if got := mr.TTL("test:new"); got != time.Hour {
+		t.Errorf("Append with KeepTTL on a new key: TTL = %v, want %v", got, time.Hour)
+	}
+}
+
+func TestFlushUnlinksPages(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestAdapter(t)
+
+	const n = 2500
+	for i := 0; i < n; i++ {
+		mr.Set(fmt.Sprintf("test:user%d", i), "v")
+	}
+	mr.Set("other:user", "v")
+
+	before := mr.CommandCount()
+	if err := r.Flush(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if keys := mr.Keys(); len(keys) != 1 || keys[0] != "other:user" {
+		t.Errorf("keys after Flush = %v, want [other:user]", keys)
+	}
+	// По SCAN и UNLINK на страницу, а не DEL на каждый ключ
+	if commands := mr.CommandCount() - before; commands > 2*(n/scanCount+2) {
+		t.Errorf("Flush sent %d commands for %d keys", commands, n)
+	}
+}
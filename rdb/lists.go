@@ -0,0 +1,277 @@
+package rdb
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// WithNativeLists включает хранение истории в виде списка Redis: каждое сообщение — отдельный элемент.
+// Добавление сообщения (memorybox.IAppender) выполняется одним Lua-скриптом RPUSH + LTRIM + PEXPIRE
+// за O(1), без перезаписи всей истории. Остальные значения (ветвлённые разговоры, индекс сессий)
+// по-прежнему хранятся строками. Старые строковые истории переводятся в списки при первой записи
+// или сразу все через MigrateToLists. Требует Redis >= 2.6 (Lua); с WithExpiryShadow добавление
+// идёт обычным путём с перезаписью, чтобы теневая копия оставалась полной.
+func WithNativeLists() Option {
+	return func(r *RedisAdapter) {
+		r.lists = true
+	}
+}
+
+// readScript читает значение любого типа: список возвращается как {"list", элементы}, строка — как {"string", значение}.
+// Если передан ARGV[1], он задаёт TTL в миллисекундах (0 снимает TTL), как GETEX.
+var readScript = redis.NewScript(`
+local t = redis.call('TYPE', KEYS[1])['ok']
+local value
+if t == 'list' then
+	value = redis.call('LRANGE', KEYS[1], 0, -1)
+elseif t == 'string' then
+	value = redis.call('GET', KEYS[1])
+else
+	return false
+end
+if ARGV[1] then
+	local ttl = tonumber(ARGV[1])
+	if ttl > 0 then
+		redis.call('PEXPIRE', KEYS[1], ttl)
+	else
+		redis.call('PERSIST', KEYS[1])
+	end
+end
+return {t, value}
+`)
+
+// replaceScript заменяет значение ключа списком ARGV[2..].
+// ARGV[1] — TTL в миллисекундах: больше нуля задаёт TTL, 0 — без TTL, -1 сохраняет текущий TTL.
+var replaceScript = redis.NewScript(`
+local ttl = tonumber(ARGV[1])
+local keep = -1
+if ttl == -1 then
+	keep = redis.call('PTTL', KEYS[1])
+end
+redis.call('DEL', KEYS[1])
+for i = 2, #ARGV, 1000 do
+	redis.call('RPUSH', KEYS[1], unpack(ARGV, i, math.min(i + 999, #ARGV)))
+end
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+elseif keep > 0 then
+	redis.call('PEXPIRE', KEYS[1], keep)
+end
+return 1
+`)
+
+// appendScript добавляет ARGV[3..] в конец списка и обрезает его до ARGV[1] элементов (0 — без ограничения),
+// сохраняя системные сообщения в начале, как memorybox.KeepSystemPolicy. Роль читается и из ключа "Role"
+// старого формата, который MigrateToLists переносит без изменений. ARGV[2] — TTL, как в replaceScript.
+// Возвращает весь список или false, если ключ хранит не список.
+var appendScript = redis.NewScript(`
+local t = redis.call('TYPE', KEYS[1])['ok']
+if t ~= 'list' and t ~= 'none' then
+	return false
+end
+local n = 0
+for i = 3, #ARGV, 1000 do
+	n = redis.call('RPUSH', KEYS[1], unpack(ARGV, i, math.min(i + 999, #ARGV)))
+end
+
+local max = tonumber(ARGV[1])
+if max > 0 and n > max then
+	local system = 0
+	while system < n do
+		local ok, msg = pcall(cjson.decode, redis.call('LINDEX', KEYS[1], system))
+		if not ok or type(msg) ~= 'table' or (msg['role'] or msg['Role']) ~= 'system' then
+			break
+		end
+		system = system + 1
+	end
+	local last = math.min(math.max(max - system, 1), n - system)
+	if last < n - system then
+		local head = {}
+		if system > 0 then
+			head = redis.call('LRANGE', KEYS[1], 0, system - 1)
+		end
+		redis.call('LTRIM', KEYS[1], -last, -1)
+		for i = #head, 1, -1 do
+			redis.call('LPUSH', KEYS[1], head[i])
+		end
+	end
+end
+
+local ttl = tonumber(ARGV[2])
+if ttl > 0 then
+	redis.call('PEXPIRE', KEYS[1], ttl)
+elseif ttl == 0 then
+	redis.call('PERSIST', KEYS[1])
+end
+return redis.call('LRANGE', KEYS[1], 0, -1)
+`)
+
+// Append реализует memorybox.IAppender: атомарно добавляет сообщения в список за O(1).
+// Возвращает ok == false без записи, если списки выключены, включены теневые копии
+// или ключ хранит строку (ветвлённый разговор или ещё не переведённую историю).
+func (r *RedisAdapter) Append(ctx context.Context, key string, items []string, maxLen int, expiration time.Duration) ([]string, bool, error) {
+	if !r.lists || r.expiryShadow || len(items) == 0 {
+		return nil, false, nil
+	}
+
+	k := r.key(key)
+	exp := r.ttl(expiration)
+	args := make([]any, 0, len(items)+2)
+	args = append(args, maxLen, ttlArg(exp))
+	for _, item := range items {
+		args = append(args, item)
+	}
+
+	var cmd *redis.Cmd
+	if r.ephemeral > 0 && exp == redis.KeepTTL {
+		// Новый список создаётся без TTL: TTL пространства имён задаётся в той же транзакции
+		_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			cmd = appendScript.Eval(ctx, pipe, []string{k}, args...)
+			r.keepEphemeral(ctx, pipe, k, exp, r.ephemeral)
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return nil, false, err
+		}
+	} else {
+		cmd = appendScript.Run(ctx, r.client, []string{k}, args...)
+	}
+	list, err := cmd.StringSlice()
+	if err == redis.Nil {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return list, true, nil
+}
+
+// MigrateToLists переводит все строковые истории под префиксом адаптера в списки, сохраняя их TTL
+// (с WithEphemeral истории без TTL получают TTL пространства имён), и возвращает число переведённых ключей. Ветвлённые разговоры и индексы сессий остаются строками.
+// Запускать можно на работающем сервисе: каждый ключ переводится в отдельной транзакции WATCH/MULTI.
+func (r *RedisAdapter) MigrateToLists(ctx context.Context) (int, error) {
+	if !r.lists {
+		return 0, fmt.Errorf("rdb: MigrateToLists requires WithNativeLists")
+	}
+
+	var migrated atomic.Int64
+	err := r.scan(ctx, func(k string) error {
+		if strings.HasSuffix(k, shadowSuffix) {
+			return nil
+		}
+
+		err := r.client.Watch(ctx, func(tx *redis.Tx) error {
+			value, err := tx.Get(ctx, k).Result()
+			if err != nil {
+				// Уже список, ключ истёк или удалён — переводить нечего
+				return nil
+			}
+			items, ok := r.listItems(value)
+			if !ok {
+				return nil
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				replaceScript.Eval(ctx, pipe, []string{k}, append([]any{ttlArg(redis.KeepTTL)}, items...)...)
+				r.keepEphemeral(ctx, pipe, k, redis.KeepTTL, r.ephemeral)
+				return nil
+			})
+			if err == nil {
+				migrated.Add(1)
+			}
+			return err
+		}, k)
+		if err == redis.TxFailedErr {
+			// Ключ изменился во время перевода; следующая запись через адаптер со списками переведёт его сама
+			return nil
+		}
+		return err
+	})
+	return int(migrated.Load()), err
+}
+
+// read получает значение ключа k любого типа; со списками элементы собираются обратно в JSON-массив.
+func (r *RedisAdapter) read(ctx context.Context, c redis.Cmdable, k string) (string, error) {
+	if !r.lists {
+		return result(c.Get(ctx, k).Result())
+	}
+	return parseRead(readScript.Run(ctx, c, []string{k}))
+}
+
+// parseRead разбирает ответ readScript.
+func parseRead(cmd *redis.Cmd) (string, error) {
+	res, err := cmd.Slice()
+	if err == redis.Nil {
+		return "", errNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	if len(res) != 2 {
+		return "", fmt.Errorf("rdb: unexpected read reply %v", res)
+	}
+
+	if items, ok := res[1].([]any); ok {
+		parts := make([]string, len(items))
+		for i, item := range items {
+			parts[i], _ = item.(string)
+		}
+		return "[" + strings.Join(parts, ",") + "]", nil
+	}
+	value, _ := res[1].(string)
+	return value, nil
+}
+
+// store добавляет в pipe запись значения: JSON-массив сообщений со списками пишется списком,
+// остальное — строкой. Заодно обновляется теневая копия. С WithEphemeral ключ, записанный с redis.KeepTTL
+// без TTL, получает TTL временного пространства имён.
+func (r *RedisAdapter) store(ctx context.Context, pipe redis.Pipeliner, key string, value any, exp time.Duration) {
+	if items, ok := r.listItems(value); ok {
+		replaceScript.Eval(ctx, pipe, []string{r.key(key)}, append([]any{ttlArg(exp)}, items...)...)
+	} else {
+		pipe.Set(ctx, r.key(key), value, exp)
+	}
+	r.keepEphemeral(ctx, pipe, r.key(key), exp, r.ephemeral)
+	if r.expiryShadow {
+		r.setShadow(ctx, pipe, key, value, exp)
+	}
+}
+
+// listItems разбивает JSON-массив объектов на элементы списка. Пустой массив и любые другие
+// значения хранятся строкой, так что Get возвращает их без изменений.
+func (r *RedisAdapter) listItems(value any) ([]any, bool) {
+	s, ok := value.(string)
+	if !r.lists || !ok || !strings.HasPrefix(strings.TrimSpace(s), "[") {
+		return nil, false
+	}
+	var raw []json.RawMessage
+	if err := json.Unmarshal([]byte(s), &raw); err != nil || len(raw) == 0 {
+		return nil, false
+	}
+
+	items := make([]any, len(raw))
+	for i, item := range raw {
+		if !strings.HasPrefix(string(item), "{") {
+			return nil, false
+		}
+		items[i] = string(item)
+	}
+	return items, true
+}
+
+// ttlArg переводит TTL в аргумент скриптов: миллисекунды, 0 — без TTL, -1 — сохранить текущий.
+func ttlArg(exp time.Duration) int64 {
+	switch {
+	case exp == redis.KeepTTL:
+		return -1
+	case exp <= 0:
+		return 0
+	default:
+		return max(exp.Milliseconds(), 1)
+	}
+}
@@ -0,0 +1,33 @@
+package rdb
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+func TestMigrateToListsKeepsOldSystemPrompt(t *testing.T) {
+	ctx := context.Background()
+	r, mr := newTestAdapter(t, WithNativeLists())
+
+	// История до появления json-тегов: ключи "Role" и "Content", без ID
+	mr.Set("test:user", `[{"Role":"system","Content":"s"},{"Role":"user","Content":"a"},{"Role":"assistant","Content":"b"}]`)
+	if n, err := r.MigrateToLists(ctx); err != nil || n != 1 {
+		t.Fatalf("MigrateToLists = %d, %v, want 1", n, err)
+	}
+
+	box := memorybox.NewMemoryBox(r, memorybox.MemoryBoxConfig{ContextLenSize: 3})
+	msgs, err := box.Tell(ctx, "user", "c")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, m := range msgs {
+		got = append(got, string(m.Role)+":"+m.Content)
+	}
+	if want := "[system:s assistant:b user:c]"; fmt.Sprint(got) != want {
+		t.Fatalf("history = %v, want %s", got, want)
+	}
+}
@@ -3,58 +3,160 @@ package rdb
 import (
 	"context"
 	"fmt"
-	"os"
-	"os/signal"
-	"syscall"
 	"time"
 
 	"github.com/go-redis/redis/v8"
 )
 
-func NewRedisAdapter(client *redis.Client, prefix string, flushSessionOnSIGINT ...bool) *RedisAdapter {
+// NewRedisAdapter создаёт адаптер поверх любого клиента go-redis: *redis.Client, клиента Sentinel
+// (redis.NewFailoverClient), *redis.ClusterClient, *redis.Ring или redis.NewUniversalClient.
+// Адаптер не перехватывает сигналы и ничего не удаляет сам: очистку включает WithFlushOnClose
+// или явный вызов Flush. Для *redis.ClusterClient с WithExpiryShadow WithHashTags включается автоматически.
+func NewRedisAdapter(client redis.UniversalClient, prefix string, opts ...Option) *RedisAdapter {
 	adapter := &RedisAdapter{client: client, prefix: prefix}
-
-	if len(flushSessionOnSIGINT) > 0 && flushSessionOnSIGINT[0] {
-		adapter.flushSessionOnSIGINT = true
-
-		// Ловим Ctrl+C
-		go func() {
-			c := make(chan os.Signal, 1)
-			signal.Notify(c, os.Interrupt, syscall.SIGTERM)
-			<-c
-			fmt.Println("Clearing redis session before exit...")
-
-			if err := adapter.ClearPrefix(context.Background()); err != nil {
-				fmt.Printf("failed to clear redis: %v\n", err)
-			}
-			os.Exit(0)
-		}()
+	for _, opt := range opts {
+		opt(adapter)
+	}
+	if _, ok := client.(*redis.ClusterClient); ok && adapter.expiryShadow {
+		// Теневая копия пишется в одной транзакции с ключом, в кластере они должны быть в одном слоте
+		adapter.hashTags = true
 	}
-
 	return adapter
 }
 
-// Set сохраняет значение с TTL (если указан)
+// Set сохраняет значение с TTL (если указан). redis.KeepTTL (memorybox.KeepTTL) сохраняет текущий TTL ключа.
 func (r *RedisAdapter) Set(ctx context.Context, key string, value any, expiration ...time.Duration) error {
 	var exp time.Duration
 	if len(expiration) > 0 {
 		exp = expiration[0]
 	}
-	return r.client.Set(ctx, r.prefix+key, value, exp).Err()
+	exp = r.ttl(exp)
+	if !r.expiryShadow && !r.lists && (r.ephemeral == 0 || exp != redis.KeepTTL) {
+		return r.client.Set(ctx, r.key(key), value, exp).Err()
+	}
+
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		r.store(ctx, pipe, key, value, exp)
+		return nil
+	})
+	return err
 }
 
-// Get получает значение по ключу
+// Get получает значение по ключу.
+// Для отсутствующего ключа возвращается ошибка, совпадающая (errors.Is) с memorybox.ErrNotFound и redis.Nil.
 func (r *RedisAdapter) Get(ctx context.Context, key string) (string, error) {
-	return r.client.Get(ctx, r.prefix+key).Result()
+	return r.read(ctx, r.client, r.key(key))
 }
 
-// ClearPrefix удаляет все ключи по текущему префиксу
-func (r *RedisAdapter) ClearPrefix(ctx context.Context) error {
-	iter := r.client.Scan(ctx, 0, r.prefix+"*", 0).Iterator()
-	for iter.Next(ctx) {
-		if err := r.client.Del(ctx, iter.Val()).Err(); err != nil {
+// Delete удаляет ключ; отсутствие ключа ошибкой не считается
+func (r *RedisAdapter) Delete(ctx context.Context, key string) error {
+	if r.expiryShadow {
+		// Отдельные DEL: без WithHashTags ключ и его копия в кластере могут оказаться в разных слотах
+		_, err := r.client.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			pipe.Del(ctx, r.key(key))
+			pipe.Del(ctx, r.key(key)+shadowSuffix)
+			return nil
+		})
+		return err
+	}
+	return r.client.Del(ctx, r.key(key)).Err()
+}
+
+// Expire задаёт TTL существующего ключа (EXPIRE); ноль или меньше снимает TTL (PERSIST).
+// Для отсутствующего ключа ошибки нет.
+func (r *RedisAdapter) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	expiration = r.ttl(expiration)
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		if expiration > 0 {
+			pipe.Expire(ctx, r.key(key), expiration)
+		} else {
+			pipe.Persist(ctx, r.key(key))
+		}
+		if r.expiryShadow {
+			r.expireShadow(ctx, pipe, key, expiration)
+		}
+		return nil
+	})
+	return err
+}
+
+// GetEx получает значение по ключу и одновременно задаёт его TTL (GETEX, Redis >= 6.2),
+// так что чтение продлевает жизнь разговора. Ноль или меньше снимает TTL.
+func (r *RedisAdapter) GetEx(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	expiration = r.ttl(expiration)
+	if r.lists {
+		var cmd *redis.Cmd
+		_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			cmd = readScript.Eval(ctx, pipe, []string{r.key(key)}, ttlArg(max(expiration, 0)))
+			if r.expiryShadow {
+				r.expireShadow(ctx, pipe, key, expiration)
+			}
+			return nil
+		})
+		if err != nil && err != redis.Nil {
+			return "", err
+		}
+		return parseRead(cmd)
+	}
+
+	var cmd *redis.StringCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		cmd = pipe.GetEx(ctx, r.key(key), max(expiration, 0))
+		if r.expiryShadow {
+			r.expireShadow(ctx, pipe, key, expiration)
+		}
+		return nil
+	})
+	if err != nil && err != redis.Nil {
+		return "", err
+	}
+	return result(cmd.Result())
+}
+
+// Update атомарно читает значение, передаёт его в fn и сохраняет результат.
+// Используется WATCH/MULTI: если ключ изменился между чтением и записью,
+// транзакция повторяется (не более maxUpdateRetries раз).
+func (r *RedisAdapter) Update(ctx context.Context, key string, fn func(old string, exists bool) (string, error), expiration ...time.Duration) error {
+	var exp time.Duration
+	if len(expiration) > 0 {
+		exp = expiration[0]
+	}
+	exp = r.ttl(exp)
+	k := r.key(key)
+
+	txf := func(tx *redis.Tx) error {
+		old, err := r.read(ctx, tx, k)
+		exists := true
+		if err == errNotFound {
+			exists = false
+		} else if err != nil {
+			return err
+		}
+
+		value, err := fn(old, exists)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+			r.store(ctx, pipe, key, value, exp)
+			return nil
+		})
+		return err
+	}
+
+	for i := 0; i < maxUpdateRetries; i++ {
+		err := r.client.Watch(ctx, txf, k)
+		if err != redis.TxFailedErr {
 			return err
 		}
 	}
-	return iter.Err()
+	return fmt.Errorf("update %q: too many concurrent writers: %w", key, redis.TxFailedErr)
+}
+
+// ClearPrefix удаляет все ключи по текущему префиксу.
+//
+// Deprecated: используйте Flush.
+func (r *RedisAdapter) ClearPrefix(ctx context.Context) error {
+	return r.Flush(ctx)
 }
@@ -0,0 +1,61 @@
+package rdb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/go-redis/redis/v8"
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+func TestAddRawConcurrent(t *testing.T) {
+	const writers, messages = 5, 10
+	ctx := context.Background()
+	r, _ := newTestAdapter(t)
+	box := memorybox.NewMemoryBox(r, memorybox.MemoryBoxConfig{})
+
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < messages; i++ {
+				if _, err := box.AddRaw(ctx, "user", memorybox.UserRole, fmt.Sprintf("%d-%d", w, i)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	msgs, err := box.GetMemories(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != writers*messages {
+		t.Fatalf("history has %d messages, want %d: concurrent writes were lost", len(msgs), writers*messages)
+	}
+}
+
+func TestGetNotFound(t *testing.T) {
+	for _, tt := range []struct {
+		name string
+		opts []Option
+	}{
+		{"strings", nil},
+		{"lists", []Option{WithNativeLists()}},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			r, _ := newTestAdapter(t, tt.opts...)
+			_, err := r.Get(context.Background(), "missing")
+			// Совпадение с redis.Nil сохраняет старые проверки через errors.Is(err, redis.Nil)
+			if !errors.Is(err, memorybox.ErrNotFound) || !errors.Is(err, redis.Nil) {
+				t.Fatalf("Get(missing) error = %v, want ErrNotFound and redis.Nil", err)
+			}
+		})
+	}
+}
@@ -1,11 +1,46 @@
 package rdb
 
 import (
+	"fmt"
+	"time"
+
 	"github.com/go-redis/redis/v8"
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
 )
 
+// errNotFound возвращается для отсутствующего ключа. Совпадает и с memorybox.ErrNotFound,
+// и с redis.Nil, так что старые проверки через errors.Is(err, redis.Nil) продолжают работать.
+var errNotFound = fmt.Errorf("%w (%w)", memorybox.ErrNotFound, redis.Nil)
+
+// result заменяет redis.Nil на errNotFound.
+func result(value string, err error) (string, error) {
+	if err == redis.Nil {
+		return "", errNotFound
+	}
+	return value, err
+}
+
+// maxUpdateRetries ограничивает число повторов оптимистичной транзакции в Update.
+const maxUpdateRetries = 100
+
+// shadowSuffix добавляется к ключу теневой копии, которая переживает основной ключ
+// и отдаёт его последнее значение обработчику истечения.
+const shadowSuffix = ":expiry-shadow"
+
 type RedisAdapter struct {
-	client               *redis.Client
-	flushSessionOnSIGINT bool
-	prefix               string
+	client redis.UniversalClient
+	prefix string
+
+	flushOnClose bool          // удалять ключи под префиксом в Close (WithFlushOnClose)
+	ephemeral    time.Duration // TTL для ключей, записанных без TTL (WithEphemeral)
+
+	expiryShadow  bool          // писать теневые копии ключей с TTL для ListenExpired
+	shadowGrace   time.Duration // насколько теневая копия живёт дольше основного ключа
+	managedNotify bool          // не менять notify-keyspace-events в ListenExpired (WithManagedNotifications)
+
+	lists    bool // хранить истории списками Redis (WithNativeLists)
+	hashTags bool // заключать ID пользователя в {} для Redis Cluster (WithHashTags)
 }
+
+// Option настраивает RedisAdapter, созданный через NewRedisAdapter.
+type Option func(*RedisAdapter)
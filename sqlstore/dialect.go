@@ -0,0 +1,94 @@
+package sqlstore
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Dialect adapts the store's queries to a database.
+// SQLite and Postgres are provided; the queries are written with ? placeholders.
+type Dialect interface {
+	// Rebind rewrites a query with ? placeholders into the database's placeholder syntax.
+	Rebind(query string) string
+
+	// Migrations returns the schema migrations in order: migration i brings the schema to version i+1.
+	// Released migrations must never change; add new ones at the end.
+	Migrations() []Migration
+
+	// LockKey returns a statement taking the key as its only argument that makes concurrent
+	// transactions on the same key wait for each other until commit.
+	LockKey() string
+}
+
+// Migration is one schema change: statements run in a single transaction.
+type Migration []string
+
+// migrations is the schema shared by the built-in dialects.
+// Times are stored as Unix milliseconds; a NULL expires_at means no TTL.
+// The TTL is kept on the key only, so that a sliding TTL does not rewrite every message row.
+var migrations = []Migration{{
+	`CREATE TABLE memorybox_keys (
+		key        TEXT PRIMARY KEY,
+		kind       TEXT NOT NULL,
+		value      TEXT,
+		expires_at BIGINT
+	)`,
+	`CREATE INDEX memorybox_keys_expires_at ON memorybox_keys (expires_at)`,
+	`CREATE TABLE memorybox_messages (
+		key        TEXT NOT NULL,
+		user_id    TEXT NOT NULL,
+		session_id TEXT NOT NULL,
+		seq        INTEGER NOT NULL,
+		id         TEXT NOT NULL,
+		role       TEXT NOT NULL,
+		content    TEXT NOT NULL,
+		created_at BIGINT,
+		extra      TEXT,
+		PRIMARY KEY (key, seq)
+	)`,
+	`CREATE INDEX memorybox_messages_user ON memorybox_messages (user_id, session_id)`,
+}}
+
+// SQLite is the dialect for SQLite, e.g. with the modernc.org/sqlite or github.com/mattn/go-sqlite3 driver.
+// Set a busy timeout on the connection (e.g. "_pragma=busy_timeout(5000)") so that concurrent writers wait
+// for each other instead of failing with SQLITE_BUSY.
+var SQLite Dialect = sqliteDialect{}
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Rebind(query string) string { return query }
+
+func (sqliteDialect) Migrations() []Migration { return migrations }
+
+// LockKey starts the write transaction right away: SQLite takes its database-wide write lock
+// on the first write statement, even one that changes no rows.
+func (sqliteDialect) LockKey() string {
+	return `UPDATE memorybox_keys SET kind = kind WHERE key = ?`
+}
+
+// Postgres is the dialect for PostgreSQL, e.g. with the github.com/jackc/pgx/v5/stdlib or github.com/lib/pq driver.
+var Postgres Dialect = postgresDialect{}
+
+type postgresDialect struct{}
+
+// Rebind replaces the ? placeholders with $1, $2, ...
+func (postgresDialect) Rebind(query string) string {
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+func (postgresDialect) Migrations() []Migration { return migrations }
+
+// LockKey takes a transaction-scoped advisory lock on the key, which also works for keys without a row yet.
+func (postgresDialect) LockKey() string {
+	return `SELECT pg_advisory_xact_lock(hashtext(?))`
+}
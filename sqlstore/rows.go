@@ -0,0 +1,323 @@
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// Kinds of stored values.
+const (
+	kindMessages = "messages" // A JSON array of messages, stored as rows of memorybox_messages.
+	kindText     = "text"     // Any other value, stored in memorybox_keys.value.
+)
+
+// querier is implemented by *sql.DB and *sql.Tx.
+type querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+}
+
+// messageRow holds the columns of a memorybox_messages row that describe the message.
+type messageRow struct {
+	id        string
+	role      string
+	content   string
+	createdAt sql.NullInt64
+	extra     sql.NullString
+}
+
+// read loads the value of key, rebuilding message histories from their rows.
+// The key and its messages are read with a single statement, so they always come from the same write.
+func (s *Store) read(ctx context.Context, q querier, key string) (string, error) {
+	rows, err := q.QueryContext(ctx, s.dialect.Rebind(
+		`SELECT k.kind, k.value, k.expires_at, m.id, m.role, m.content, m.created_at, m.extra
+		FROM memorybox_keys k LEFT JOIN memorybox_messages m ON m.key = k.key
+		WHERE k.key = ? ORDER BY m.seq`), key)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+
+	found := false
+	var kind string
+	var value sql.NullString
+	var expiresAt sql.NullInt64
+	msgs := []memorybox.Message{}
+	for rows.Next() {
+		var id, role, content sql.NullString
+		var r messageRow
+		if err := rows.Scan(&kind, &value, &expiresAt, &id, &role, &content, &r.createdAt, &r.extra); err != nil {
+			return "", err
+		}
+		found = true
+		if !id.Valid {
+			continue // A key without message rows
+		}
+		r.id, r.role, r.content = id.String, role.String, content.String
+		m, err := r.message()
+		if err != nil {
+			return "", fmt.Errorf("%w: message %q of %q: %w", memorybox.ErrCorrupted, r.id, key, err)
+		}
+		msgs = append(msgs, m)
+	}
+	if err := rows.Err(); err != nil {
+		return "", err
+	}
+
+	switch {
+	case !found:
+		return "", memorybox.ErrNotFound
+	case s.expired(expiresAt):
+		return "", memorybox.ErrExpired
+	case kind != kindMessages:
+		return value.String, nil
+	}
+	data, err := json.Marshal(msgs)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// write replaces the value of key. The caller must hold the key lock.
+func (s *Store) write(ctx context.Context, tx *sql.Tx, key string, value string, expiration ...time.Duration) error {
+	expiresAt, err := s.expiresAt(ctx, tx, key, expiration...)
+	if err != nil {
+		return err
+	}
+
+	msgs, isHistory := decodeMessages(value)
+	kind, text := kindText, sql.NullString{String: value, Valid: true}
+	if isHistory {
+		kind, text = kindMessages, sql.NullString{}
+	}
+
+	if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+		`INSERT INTO memorybox_keys (key, kind, value, expires_at) VALUES (?, ?, ?, ?)
+		ON CONFLICT (key) DO UPDATE SET kind = excluded.kind, value = excluded.value, expires_at = excluded.expires_at`),
+		key, kind, text, expiresAt); err != nil {
+		return err
+	}
+	if !isHistory {
+		_, err := tx.ExecContext(ctx, s.dialect.Rebind(`DELETE FROM memorybox_messages WHERE key = ?`), key)
+		return err
+	}
+	return s.writeMessages(ctx, tx, key, msgs)
+}
+
+// writeMessages makes the message rows of key match msgs. Rows of messages that are still
+// in the history, unchanged and in order, are kept; only the others are deleted and only
+// the new messages are inserted. An append thus costs one insert, plus a delete per trimmed message.
+// The caller must hold the key lock.
+func (s *Store) writeMessages(ctx context.Context, tx *sql.Tx, key string, msgs []memorybox.Message) error {
+	want := make([]messageRow, len(msgs))
+	for i, m := range msgs {
+		var err error
+		if want[i], err = newMessageRow(m); err != nil {
+			return err
+		}
+	}
+
+	rows, err := tx.QueryContext(ctx, s.dialect.Rebind(
+		`SELECT seq, id, role, content, created_at, extra FROM memorybox_messages WHERE key = ? ORDER BY seq`), key)
+	if err != nil {
+		return err
+	}
+	next := 0         // Index of the first message not matched by a kept row
+	last := int64(-1) // Highest seq in use
+	var stale []int64
+	for rows.Next() {
+		var seq int64
+		var r messageRow
+		if err := rows.Scan(&seq, &r.id, &r.role, &r.content, &r.createdAt, &r.extra); err != nil {
+			rows.Close()
+			return err
+		}
+		last = seq
+		if next < len(want) && r == want[next] {
+			next++
+			continue
+		}
+		stale = append(stale, seq)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, seq := range stale {
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`DELETE FROM memorybox_messages WHERE key = ? AND seq = ?`), key, seq); err != nil {
+			return err
+		}
+	}
+	if next == len(want) {
+		return nil
+	}
+
+	stmt, err := tx.PrepareContext(ctx, s.dialect.Rebind(
+		`INSERT INTO memorybox_messages (key, user_id, session_id, seq, id, role, content, created_at, extra)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	userid, sessionID, ok := memorybox.ParseSessionKey(key)
+	if !ok {
+		userid, sessionID = key, ""
+	}
+	for i, r := range want[next:] {
+		if _, err := stmt.ExecContext(ctx, key, userid, sessionID, last+1+int64(i), r.id, r.role, r.content,
+			r.createdAt, r.extra); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove deletes key and its messages. The caller must hold the key lock.
+func (s *Store) remove(ctx context.Context, tx *sql.Tx, key string) error {
+	if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+		`DELETE FROM memorybox_messages WHERE key = ?`), key); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, s.dialect.Rebind(`DELETE FROM memorybox_keys WHERE key = ?`), key)
+	return err
+}
+
+// expire sets the TTL of an existing, unexpired key. The caller must hold the key lock.
+func (s *Store) expire(ctx context.Context, tx *sql.Tx, key string, expiration time.Duration) error {
+	var current sql.NullInt64
+	err := tx.QueryRowContext(ctx, s.dialect.Rebind(
+		`SELECT expires_at FROM memorybox_keys WHERE key = ?`), key).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if s.expired(current) || expiration == memorybox.KeepTTL {
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, s.dialect.Rebind(
+		`UPDATE memorybox_keys SET expires_at = ? WHERE key = ?`), s.deadline(expiration), key)
+	return err
+}
+
+// expiresAt returns the expiration time for a write of key.
+// memorybox.KeepTTL keeps the expiration time of an existing, unexpired key.
+func (s *Store) expiresAt(ctx context.Context, tx *sql.Tx, key string, expiration ...time.Duration) (sql.NullInt64, error) {
+	if len(expiration) == 0 {
+		return sql.NullInt64{}, nil
+	}
+	if expiration[0] != memorybox.KeepTTL {
+		return s.deadline(expiration[0]), nil
+	}
+
+	var current sql.NullInt64
+	err := tx.QueryRowContext(ctx, s.dialect.Rebind(
+		`SELECT expires_at FROM memorybox_keys WHERE key = ?`), key).Scan(&current)
+	if errors.Is(err, sql.ErrNoRows) || s.expired(current) {
+		return sql.NullInt64{}, nil
+	}
+	return current, err
+}
+
+// deadline returns the expiration time for a TTL, NULL when the TTL is zero or less.
+func (s *Store) deadline(ttl time.Duration) sql.NullInt64 {
+	if ttl <= 0 {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: s.clock.Now().Add(ttl).UnixMilli(), Valid: true}
+}
+
+// expired reports whether an expiration time has passed.
+func (s *Store) expired(expiresAt sql.NullInt64) bool {
+	return expiresAt.Valid && s.clock.Now().UnixMilli() > expiresAt.Int64
+}
+
+// messageFields holds the JSON field names of memorybox.Message.
+var messageFields = func() map[string]bool {
+	fields := map[string]bool{}
+	t := reflect.TypeFor[memorybox.Message]()
+	for i := range t.NumField() {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		fields[name] = true
+	}
+	return fields
+}()
+
+// decodeMessages parses value as a message history. It reports false for any value
+// that would not survive being stored as rows, e.g. arrays of other objects or unknown fields.
+func decodeMessages(value string) ([]memorybox.Message, bool) {
+	if !strings.HasPrefix(strings.TrimSpace(value), "[") {
+		return nil, false
+	}
+
+	var objects []map[string]json.RawMessage
+	if err := json.Unmarshal([]byte(value), &objects); err != nil {
+		return nil, false
+	}
+	for _, obj := range objects {
+		if obj == nil {
+			return nil, false
+		}
+		for field := range obj {
+			if !messageFields[field] {
+				return nil, false
+			}
+		}
+	}
+
+	var msgs []memorybox.Message
+	if err := json.Unmarshal([]byte(value), &msgs); err != nil {
+		return nil, false
+	}
+	return msgs, true
+}
+
+// newMessageRow returns the columns of m. Fields without a column of their own go to extra as JSON.
+// ParentID is not stored: in a history the parent is the previous row, and MemoryBox relinks
+// the messages when it loads them. Storing it would change the first kept row on every trim.
+func newMessageRow(m memorybox.Message) (messageRow, error) {
+	r := messageRow{id: m.ID, role: string(m.Role), content: m.Content}
+	if !m.CreatedAt.IsZero() {
+		r.createdAt = sql.NullInt64{Int64: m.CreatedAt.UnixMilli(), Valid: true}
+	}
+
+	rest := m
+	rest.ID, rest.Role, rest.Content, rest.CreatedAt, rest.ParentID = "", "", "", time.Time{}, ""
+	if reflect.DeepEqual(rest, memorybox.Message{}) {
+		return r, nil
+	}
+	data, err := json.Marshal(rest)
+	if err != nil {
+		return r, err
+	}
+	r.extra = sql.NullString{String: string(data), Valid: true}
+	return r, nil
+}
+
+// message rebuilds the message stored in r.
+func (r messageRow) message() (memorybox.Message, error) {
+	var m memorybox.Message
+	if r.extra.Valid {
+		if err := json.Unmarshal([]byte(r.extra.String), &m); err != nil {
+			return m, err
+		}
+	}
+	m.ID, m.Role, m.Content = r.id, memorybox.Role(r.role), r.content
+	if r.createdAt.Valid {
+		m.CreatedAt = time.UnixMilli(r.createdAt.Int64)
+	}
+	return m, nil
+}
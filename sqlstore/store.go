@@ -0,0 +1,242 @@
+// Package sqlstore is a memorybox backend on top of database/sql: a durable, single-file
+// store with SQLite or a shared one with PostgreSQL, without running Redis.
+//
+// Histories are stored as one row per message (user, session, seq, role, content, created_at),
+// so they can be queried with SQL; appending a message inserts one row. The TTL is tracked in
+// memorybox_keys.expires_at. Other values, such as branched conversations and session indexes,
+// are stored as a single text value.
+//
+//	db, _ := sql.Open("sqlite", "memory.db?_pragma=busy_timeout(5000)") // import _ "modernc.org/sqlite"
+//	store, err := sqlstore.New(ctx, db, sqlstore.SQLite, sqlstore.WithCleanup(time.Minute))
+//	defer store.Close()
+//	box := memorybox.NewMemoryBox(store, memorybox.MemoryBoxConfig{ExpireTime: time.Hour})
+package sqlstore
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+)
+
+// Store implements memorybox.IMemorizer, IUpdater and IExpirer on a SQL database.
+type Store struct {
+	db      *sql.DB
+	dialect Dialect
+	clock   memorybox.Clock
+
+	cleanupInterval time.Duration  // How often expired keys are deleted; zero disables it.
+	stop            chan struct{}  // Closed by Close to stop the cleanup.
+	closeOnce       sync.Once      // Makes Close idempotent.
+	background      sync.WaitGroup // Tracks the cleanup goroutine.
+}
+
+// Option configures a Store created by New.
+type Option func(*Store)
+
+// WithCleanup starts a background goroutine that deletes expired keys every interval.
+// Without it expired keys are never returned but stay in the database until DeleteExpired is called.
+func WithCleanup(interval time.Duration) Option {
+	return func(s *Store) {
+		s.cleanupInterval = interval
+	}
+}
+
+// WithClock makes the store read the time from clock instead of the system clock.
+func WithClock(clock memorybox.Clock) Option {
+	return func(s *Store) {
+		s.clock = clock
+	}
+}
+
+// New creates a store on db and brings its schema up to date with Migrate.
+// The store does not own db: Close stops the cleanup but leaves db open.
+func New(ctx context.Context, db *sql.DB, dialect Dialect, opts ...Option) (*Store, error) {
+	s := &Store{
+		db:      db,
+		dialect: dialect,
+		clock:   memorybox.SystemClock,
+		stop:    make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if err := s.Migrate(ctx); err != nil {
+		return nil, err
+	}
+	if s.cleanupInterval > 0 {
+		ticker := s.clock.NewTicker(s.cleanupInterval)
+		s.background.Add(1)
+		go s.cleanup(ticker)
+	}
+	return s, nil
+}
+
+// Close stops the background cleanup, if any. It is safe to call Close more than once.
+func (s *Store) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.stop)
+		s.background.Wait()
+	})
+	return nil
+}
+
+// Set stores a value with an optional expiration time. memorybox.KeepTTL keeps the TTL of an existing key.
+// JSON arrays of messages, as written by MemoryBox, are stored as message rows; other values as text.
+func (s *Store) Set(ctx context.Context, key string, value any, expiration ...time.Duration) error {
+	return s.tx(ctx, key, func(tx *sql.Tx) error {
+		return s.write(ctx, tx, key, fmt.Sprintf("%v", value), expiration...)
+	})
+}
+
+// Get returns the value of key, memorybox.ErrNotFound if it does not exist
+// or memorybox.ErrExpired if its TTL has passed.
+func (s *Store) Get(ctx context.Context, key string) (string, error) {
+	return s.read(ctx, s.db, key)
+}
+
+// Update atomically reads the value stored under key, passes it to fn and stores the result.
+// The read and the write run in one transaction holding the dialect's key lock.
+// Expired keys are reported to fn as missing.
+func (s *Store) Update(ctx context.Context, key string, fn func(old string, exists bool) (string, error), expiration ...time.Duration) error {
+	return s.tx(ctx, key, func(tx *sql.Tx) error {
+		old, err := s.read(ctx, tx, key)
+		exists := err == nil
+		if err != nil && !errors.Is(err, memorybox.ErrNotFound) {
+			return err
+		}
+
+		value, err := fn(old, exists)
+		if err != nil {
+			return err
+		}
+		return s.write(ctx, tx, key, value, expiration...)
+	})
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (s *Store) Delete(ctx context.Context, key string) error {
+	return s.tx(ctx, key, func(tx *sql.Tx) error {
+		return s.remove(ctx, tx, key)
+	})
+}
+
+// Expire sets the TTL of an existing key; zero or less removes the TTL.
+// Expiring a missing or expired key is not an error.
+func (s *Store) Expire(ctx context.Context, key string, expiration time.Duration) error {
+	return s.tx(ctx, key, func(tx *sql.Tx) error {
+		return s.expire(ctx, tx, key, expiration)
+	})
+}
+
+// GetEx returns the value of key like Get and sets its TTL like Expire.
+func (s *Store) GetEx(ctx context.Context, key string, expiration time.Duration) (string, error) {
+	var value string
+	err := s.tx(ctx, key, func(tx *sql.Tx) error {
+		var err error
+		if value, err = s.read(ctx, tx, key); err != nil {
+			return err
+		}
+		return s.expire(ctx, tx, key, expiration)
+	})
+	return value, err
+}
+
+// DeleteExpired removes all keys whose TTL has passed and returns how many were removed.
+func (s *Store) DeleteExpired(ctx context.Context) (int64, error) {
+	now := s.clock.Now().UnixMilli()
+	var n int64
+	err := s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`DELETE FROM memorybox_messages WHERE key IN (SELECT key FROM memorybox_keys WHERE expires_at < ?)`), now); err != nil {
+			return err
+		}
+		res, err := tx.ExecContext(ctx, s.dialect.Rebind(
+			`DELETE FROM memorybox_keys WHERE expires_at < ?`), now)
+		if err != nil {
+			return err
+		}
+		n, err = res.RowsAffected()
+		return err
+	})
+	return n, err
+}
+
+// cleanup deletes expired keys on every tick until Close is called.
+func (s *Store) cleanup(ticker memorybox.Ticker) {
+	defer s.background.Done()
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C():
+			if _, err := s.DeleteExpired(context.Background()); err != nil {
+				slog.Error("sqlstore: delete expired", "err", err)
+			}
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+// Migrate applies the dialect's migrations that the database does not have yet.
+// The applied version is recorded in the memorybox_schema table.
+func (s *Store) Migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS memorybox_schema (version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+
+	var version int
+	err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM memorybox_schema`).Scan(&version)
+	if err != nil {
+		return err
+	}
+
+	for i, migration := range s.dialect.Migrations() {
+		if i < version {
+			continue
+		}
+		err := s.inTx(ctx, func(tx *sql.Tx) error {
+			for _, stmt := range migration {
+				if _, err := tx.ExecContext(ctx, stmt); err != nil {
+					return err
+				}
+			}
+			_, err := tx.ExecContext(ctx, s.dialect.Rebind(`INSERT INTO memorybox_schema (version) VALUES (?)`), i+1)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("sqlstore: migration %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// tx runs fn in a transaction that holds the lock of key.
+func (s *Store) tx(ctx context.Context, key string, fn func(*sql.Tx) error) error {
+	return s.inTx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, s.dialect.Rebind(s.dialect.LockKey()), key); err != nil {
+			return err
+		}
+		return fn(tx)
+	})
+}
+
+// inTx runs fn in a transaction, committing it if fn succeeds and rolling it back otherwise.
+func (s *Store) inTx(ctx context.Context, fn func(*sql.Tx) error) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
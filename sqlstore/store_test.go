@@ -0,0 +1,226 @@
+package sqlstore_test
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rmay1er/magic-memory-box-go/memorybox"
+	"github.com/rmay1er/magic-memory-box-go/memorybox/clocktest"
+	"github.com/rmay1er/magic-memory-box-go/sqlstore"
+	_ "modernc.org/sqlite"
+)
+
+// openSQLite returns a store on a fresh SQLite database file and the database itself.
+func openSQLite(t *testing.T, opts ...sqlstore.Option) (*sqlstore.Store, *sql.DB) {
+	t.Helper()
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "memory.db")+"?_pragma=busy_timeout(10000)")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	store, err := sqlstore.New(context.Background(), db, sqlstore.SQLite, opts...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { store.Close() })
+	return store, db
+}
+
+// rowIDs returns the SQLite rowids of the message rows of key in history order.
+// A row that is deleted and inserted again gets a new rowid.
+func rowIDs(t *testing.T, db *sql.DB, key string) []int64 {
+	t.Helper()
+	rows, err := db.Query(`SELECT rowid FROM memorybox_messages WHERE key = ? ORDER BY seq`, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	var ids []int64
+	for rows.Next() {
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func contents(msgs []memorybox.Message) string {
+	var s string
+	for _, m := range msgs {
+		s += string(m.Role) + ":" + m.Content + " "
+	}
+	return s
+}
+
+func TestStoreSetGetDelete(t *testing.T) {
+	ctx := context.Background()
+	store, _ := openSQLite(t)
+
+	if _, err := store.Get(ctx, "missing"); !errors.Is(err, memorybox.ErrNotFound) {
+		t.Fatalf("Get(missing) error = %v, want ErrNotFound", err)
+	}
+	if err := store.Set(ctx, "text", "plain value"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Get(ctx, "text"); err != nil || got != "plain value" {
+		t.Fatalf("Get(text) = %q, %v", got, err)
+	}
+
+	// A text value replacing a history removes its message rows and back.
+	history := `[{"id":"1","role":"user","content":"hi","metadata":{"model":"x"}}]`
+	if err := store.Set(ctx, "text", history); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Get(ctx, "text"); err != nil || got != history {
+		t.Fatalf("Get(history) = %q, %v; want %q", got, err, history)
+	}
+	if err := store.Set(ctx, "text", "again"); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := store.Get(ctx, "text"); err != nil || got != "again" {
+		t.Fatalf("Get(text) = %q, %v", got, err)
+	}
+
+	if err := store.Delete(ctx, "text"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "text"); !errors.Is(err, memorybox.ErrNotFound) {
+		t.Fatalf("Get after Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+func TestStoreAppendInsertsOnlyNewRows(t *testing.T) {
+	ctx := context.Background()
+	store, db := openSQLite(t)
+	box := memorybox.NewMemoryBox(store, memorybox.MemoryBoxConfig{ContextLenSize: 4, ExpireTime: time.Hour})
+
+	box.AddRaw(ctx, "user", memorybox.SystemRole, "sys")
+	box.Tell(ctx, "user", "q1")
+	box.Remember(ctx, "user", "a1")
+	before := rowIDs(t, db, "user")
+
+	msgs, err := box.Tell(ctx, "user", "q2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	after := rowIDs(t, db, "user")
+	if len(after) != 4 || fmt.Sprint(after[:3]) != fmt.Sprint(before) {
+		t.Fatalf("rows %v after append, want %v plus one new row", after, before)
+	}
+
+	// Trimming deletes the oldest row and keeps the others.
+	msgs, err = box.Remember(ctx, "user", "a2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := contents(msgs), "system:sys assistant:a1 user:q2 assistant:a2 "; got != want {
+		t.Fatalf("history = %q, want %q", got, want)
+	}
+	trimmed := rowIDs(t, db, "user")
+	if len(trimmed) != 4 || trimmed[0] != after[0] || fmt.Sprint(trimmed[1:3]) != fmt.Sprint(after[2:4]) {
+		t.Fatalf("rows %v after trim, want %v without the second row plus one new row", trimmed, after)
+	}
+}
+
+func TestStoreEditKeepsOrder(t *testing.T) {
+	ctx := context.Background()
+	store, _ := openSQLite(t)
+	box := memorybox.NewMemoryBox(store, memorybox.MemoryBoxConfig{})
+
+	box.Tell(ctx, "user", "q1")
+	box.Remember(ctx, "user", "a1")
+	msgs, _ := box.Tell(ctx, "user", "q2")
+
+	if _, err := box.EditMessage(ctx, "user", msgs[1].ID, "a1 edited"); err != nil {
+		t.Fatal(err)
+	}
+	got, err := box.GetMemories(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "user:q1 assistant:a1 edited user:q2 "; contents(got) != want {
+		t.Fatalf("history = %q, want %q", contents(got), want)
+	}
+}
+
+func TestStoreExpiry(t *testing.T) {
+	ctx := context.Background()
+	clock := clocktest.New(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	store, db := openSQLite(t, sqlstore.WithClock(clock))
+	box := memorybox.NewMemoryBox(store, memorybox.MemoryBoxConfig{ExpireTime: time.Minute})
+
+	box.Tell(ctx, "user", "hi")
+	box.Tell(ctx, "other", "hi")
+	clock.Advance(30 * time.Second)
+	box.Tell(ctx, "other", "still here") // Slides the TTL of other
+	clock.Advance(45 * time.Second)
+
+	if _, err := store.Get(ctx, "user"); !errors.Is(err, memorybox.ErrExpired) {
+		t.Fatalf("Get(user) error = %v, want ErrExpired", err)
+	}
+	n, err := store.DeleteExpired(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("DeleteExpired = %d, %v; want 1", n, err)
+	}
+	if ids := rowIDs(t, db, "user"); len(ids) != 0 {
+		t.Fatalf("expired user still has %d message rows", len(ids))
+	}
+	msgs, err := box.GetMemories(ctx, "other")
+	if err != nil || len(msgs) != 2 {
+		t.Fatalf("GetMemories(other) = %d messages, %v; want 2", len(msgs), err)
+	}
+}
+
+func TestStoreConcurrentAppends(t *testing.T) {
+	ctx := context.Background()
+	store, _ := openSQLite(t)
+	box := memorybox.NewMemoryBox(store, memorybox.MemoryBoxConfig{})
+
+	const writers, each = 4, 10
+	var wg sync.WaitGroup
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < each; i++ {
+				if _, err := box.Tell(ctx, "user", fmt.Sprintf("%d-%d", w, i)); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+
+	msgs, err := box.GetMemories(ctx, "user")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(msgs) != writers*each {
+		t.Fatalf("got %d messages, want %d: concurrent appends were lost", len(msgs), writers*each)
+	}
+}
+
+func TestStoreCorrupted(t *testing.T) {
+	ctx := context.Background()
+	store, db := openSQLite(t)
+
+	if err := store.Set(ctx, "user", `[{"id":"1","role":"user","content":"hi"}]`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := db.Exec(`UPDATE memorybox_messages SET extra = '{' WHERE key = 'user'`); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := store.Get(ctx, "user"); !errors.Is(err, memorybox.ErrCorrupted) {
+		t.Fatalf("Get(corrupted) error = %v, want ErrCorrupted", err)
+	}
+}